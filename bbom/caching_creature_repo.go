@@ -12,19 +12,38 @@ type ConnectionOpener interface {
 	OpenConnection() (*sql.DB, error)
 }
 
+type CachingCreatureRepoOptions struct {
+	CacheDuration time.Duration
+	// NegativeCacheDuration controls how long not-found results are cached for, nil means the same as CacheDuration and
+	// zero disables caching of not-found results altogether
+	NegativeCacheDuration *time.Duration
+}
+
 type CachingCreatureRepo struct {
-	connectionOpener ConnectionOpener
-	cacheDuration    time.Duration
+	connectionOpener      ConnectionOpener
+	cacheDuration         time.Duration
+	negativeCacheDuration time.Duration
 
 	cache      map[int64]CreatureLookupResult
 	cacheMutex sync.RWMutex
 }
 
 func NewCachingCreatureRepo(connectionOpener ConnectionOpener, cacheDuration time.Duration) *CachingCreatureRepo {
+	return NewCachingCreatureRepoWithOptions(connectionOpener, CachingCreatureRepoOptions{
+		CacheDuration: cacheDuration,
+	})
+}
+
+func NewCachingCreatureRepoWithOptions(connectionOpener ConnectionOpener, options CachingCreatureRepoOptions) *CachingCreatureRepo {
+	negativeCacheDuration := options.CacheDuration
+	if options.NegativeCacheDuration != nil {
+		negativeCacheDuration = *options.NegativeCacheDuration
+	}
 	return &CachingCreatureRepo{
-		cache:            make(map[int64]CreatureLookupResult),
-		connectionOpener: connectionOpener,
-		cacheDuration:    cacheDuration,
+		cache:                 make(map[int64]CreatureLookupResult),
+		connectionOpener:      connectionOpener,
+		cacheDuration:         options.CacheDuration,
+		negativeCacheDuration: negativeCacheDuration,
 	}
 }
 
@@ -33,7 +52,6 @@ func (c *CachingCreatureRepo) CreateCreature(ctx context.Context, name, descript
 	if err != nil {
 		return Creature{}, err
 	}
-	defer db.Close()
 	stmt, err := db.PrepareContext(ctx, "insert into creatures (name, description) values ($1, $2) returning id")
 	if err != nil {
 		return Creature{}, err
@@ -65,9 +83,12 @@ func (c *CachingCreatureRepo) GetCreature(ctx context.Context, id int64) (Creatu
 	if err != nil {
 		return CreatureLookupResult{}, err
 	}
-	defer db.Close()
 	c.cacheMutex.RLock()
-	if result, cached := c.cache[id]; cached && time.Now().Sub(result.timestamp) < c.cacheDuration {
+	if result, cached := c.cache[id]; cached && result.ResultFound && time.Now().Sub(result.timestamp) < c.cacheDuration {
+		c.cacheMutex.RUnlock()
+		return result, nil
+	}
+	if result, cached := c.cache[id]; cached && !result.ResultFound && time.Now().Sub(result.timestamp) < c.negativeCacheDuration {
 		c.cacheMutex.RUnlock()
 		return result, nil
 	}
@@ -75,7 +96,10 @@ func (c *CachingCreatureRepo) GetCreature(ctx context.Context, id int64) (Creatu
 	c.cacheMutex.Lock()
 	defer c.cacheMutex.Unlock()
 	// let's make sure another concurrent request didn't already do the query, and if so lets return its result
-	if result, cached := c.cache[id]; cached && time.Now().Sub(result.timestamp) < c.cacheDuration {
+	if result, cached := c.cache[id]; cached && result.ResultFound && time.Now().Sub(result.timestamp) < c.cacheDuration {
+		return result, nil
+	}
+	if result, cached := c.cache[id]; cached && !result.ResultFound && time.Now().Sub(result.timestamp) < c.negativeCacheDuration {
 		return result, nil
 	}
 	stmt, err := db.PrepareContext(ctx, "select name, description from creatures where id=$1")
@@ -92,7 +116,9 @@ func (c *CachingCreatureRepo) GetCreature(ctx context.Context, id int64) (Creatu
 				ResultFound: false,
 				timestamp:   time.Now(),
 			}
-			c.cache[id] = ret
+			if c.negativeCacheDuration > 0 {
+				c.cache[id] = ret
+			}
 			return ret, nil
 		} else {
 			return CreatureLookupResult{}, err
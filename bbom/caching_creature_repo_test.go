@@ -24,6 +24,7 @@ func TestCachingCreatureRepo_CreateCreature(t *testing.T) {
 	connectionCfg, err := db.ConnectionParamsFromEnv()
 	require.NoError(t, err)
 	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
 
 	testInstance := NewCachingCreatureRepo(connectionOpener, testCacheDuration)
 	creature, err := testInstance.CreateCreature(ctx, name, description)
@@ -33,7 +34,6 @@ func TestCachingCreatureRepo_CreateCreature(t *testing.T) {
 
 	conn, err := connectionOpener.OpenConnection()
 	require.NoError(t, err)
-	defer conn.Close()
 	//let's ensure data was written to postgres as expected
 	stmt, err := conn.PrepareContext(ctx, "select name, description from creatures where id=$1")
 	require.NoError(t, err)
@@ -76,12 +76,12 @@ func TestCachingCreatureRepo_GetCreature(t *testing.T) {
 	connectionCfg, err := db.ConnectionParamsFromEnv()
 	require.NoError(t, err)
 	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
 
 	testInstance := NewCachingCreatureRepo(connectionOpener, testCacheDuration)
 
 	conn, err := connectionOpener.OpenConnection()
 	require.NoError(t, err)
-	defer conn.Close()
 
 	stmt, err := conn.PrepareContext(ctx, "insert into creatures (name, description) values ($1, $2)")
 	require.NoError(t, err)
@@ -138,13 +138,13 @@ func TestCachingCreatureRepo_GetCreature_Concurrency(t *testing.T) {
 	connectionCfg, err := db.ConnectionParamsFromEnv()
 	require.NoError(t, err)
 	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
 
 	testInstance := NewCachingCreatureRepo(connectionOpener, testCacheDuration)
 
 	conn, err := connectionOpener.OpenConnection()
 	require.NoError(t, err)
-	defer conn.Close()
-	
+
 	stmt, err := conn.PrepareContext(ctx, "insert into creatures (name, description) values ($1, $2)")
 	require.NoError(t, err)
 	defer stmt.Close()
@@ -181,3 +181,79 @@ func TestCachingCreatureRepo_GetCreature_Concurrency(t *testing.T) {
 	wg.Wait()
 	// note - it would be good to ensure that only one call to the DB was made, but we can't :sad-panda:
 }
+
+func TestCachingCreatureRepo_GetCreature_NegativeCacheDuration(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		negativeCacheDuration time.Duration
+		expectNotFoundCached  bool
+	}{
+		{
+			name:                  "shorter negative cache duration",
+			negativeCacheDuration: time.Millisecond * 250,
+			expectNotFoundCached:  true,
+		},
+		{
+			name:                  "negative caching disabled",
+			negativeCacheDuration: 0,
+			expectNotFoundCached:  false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			connectionCfg, err := db.ConnectionParamsFromEnv()
+			require.NoError(t, err)
+			connectionOpener := db.NewConnectionOpener(connectionCfg)
+			defer connectionOpener.Close()
+
+			testInstance := NewCachingCreatureRepoWithOptions(connectionOpener, CachingCreatureRepoOptions{
+				CacheDuration:         time.Hour,
+				NegativeCacheDuration: &tc.negativeCacheDuration,
+			})
+
+			conn, err := connectionOpener.OpenConnection()
+			require.NoError(t, err)
+
+			// one creature we look up while it exists, and one we look up before it exists
+			foundName := fmt.Sprintf("creature_test_%s", uuid.NewString())
+			var foundID int64
+			err = conn.QueryRowContext(ctx, "insert into creatures (name, description) values ($1, $2) returning id", foundName, "a creature for testing purposes").Scan(&foundID)
+			require.NoError(t, err)
+			laterName := fmt.Sprintf("creature_test_%s", uuid.NewString())
+			var laterID int64
+			err = conn.QueryRowContext(ctx, "insert into creatures (name, description) values ($1, $2) returning id", laterName, "a creature for testing purposes").Scan(&laterID)
+			require.NoError(t, err)
+			_, err = conn.ExecContext(ctx, "delete from creatures where id=$1", laterID)
+			require.NoError(t, err)
+
+			result, err := testInstance.GetCreature(ctx, foundID)
+			require.NoError(t, err)
+			assert.True(t, result.ResultFound)
+			result, err = testInstance.GetCreature(ctx, laterID)
+			require.NoError(t, err)
+			assert.False(t, result.ResultFound)
+
+			// now flip things around behind the cache's back
+			_, err = conn.ExecContext(ctx, "delete from creatures where id=$1", foundID)
+			require.NoError(t, err)
+			_, err = conn.ExecContext(ctx, "insert into creatures (id, name, description) values ($1, $2, $3)", laterID, laterName, "a creature for testing purposes")
+			require.NoError(t, err)
+			defer conn.ExecContext(ctx, "delete from creatures where id=$1", laterID)
+
+			result, err = testInstance.GetCreature(ctx, laterID)
+			require.NoError(t, err)
+			assert.Equal(t, !tc.expectNotFoundCached, result.ResultFound)
+
+			time.Sleep(tc.negativeCacheDuration)
+			result, err = testInstance.GetCreature(ctx, laterID)
+			require.NoError(t, err)
+			assert.True(t, result.ResultFound)
+			// while the found entry lives on for the full cache duration
+			result, err = testInstance.GetCreature(ctx, foundID)
+			require.NoError(t, err)
+			assert.True(t, result.ResultFound)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Command migrate applies the embedded migrations to the database described by the POSTGRES_ environment variables
+// (see db.ConnectionParamsFromEnv).
+//
+//	migrate up               apply every migration not yet applied
+//	migrate down             roll back the latest migration
+//	migrate to <version>     migrate up or down to version, 0 rolling back everything
+//	migrate force <version>  record the database as clean at version, after fixing a dirty migration by hand
+//	migrate status           show the version the database is at and the migrations applied
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"github.com/jonsabados/srp-sample/db"
+	"github.com/jonsabados/srp-sample/migrations"
+)
+
+const usage = "usage: migrate up | down | to <version> | force <version> | status"
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := run(ctx, os.Args[1:])
+	stop()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	if err != nil {
+		return err
+	}
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+	migrator := db.NewMigrator(connectionOpener, migrations.FS)
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		return migrator.Up(ctx)
+	case args[0] == "down" && len(args) == 1:
+		return migrator.Down(ctx)
+	case (args[0] == "to" || args[0] == "force") && len(args) == 2:
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if args[0] == "to" {
+			return migrator.To(ctx, version)
+		}
+		return migrator.Force(ctx, version)
+	case args[0] == "status" && len(args) == 1:
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		dirty := ""
+		if status.Dirty {
+			dirty = " (dirty)"
+		}
+		fmt.Printf("version %d%s\n", status.Version, dirty)
+		for _, migration := range status.Migrations {
+			applied := "pending"
+			if migration.Applied {
+				applied = "applied"
+			}
+			fmt.Printf("%d\t%s\t%s\n", migration.Version, migration.Name, applied)
+		}
+		return nil
+	default:
+		return errors.New(usage)
+	}
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+type Credentials struct {
+	// User may be left empty to keep the user from the connection params
+	User     string
+	Password string
+}
+
+// CredentialProvider hands out the credentials to connect with. It is consulted every time a new connection is made,
+// so credentials that get rotated take effect without a restart. Connections already made are unaffected.
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// StaticCredentials always hands out the same credentials
+type StaticCredentials Credentials
+
+func (s StaticCredentials) Credentials(_ context.Context) (Credentials, error) {
+	return Credentials(s), nil
+}
+
+// PasswordFileCredentials reads the password from a file every time it is asked for credentials, such as a secret
+// mounted into a container that gets replaced as the password is rotated. Trailing line breaks are ignored.
+type PasswordFileCredentials string
+
+func (p PasswordFileCredentials) Credentials(_ context.Context) (Credentials, error) {
+	password, err := readPasswordFile(string(p))
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{Password: password}, nil
+}
+
+func readPasswordFile(path string) (string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading password file: %w", err)
+	}
+	return strings.TrimRight(string(raw), "\r\n"), nil
+}
+
+// credentialConnector makes connections with whatever credentials its provider hands out at the time
+type credentialConnector struct {
+	connectionParams ConnectionParams
+	credentials      CredentialProvider
+}
+
+func (c *credentialConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	dataSourceName, err := c.dataSourceName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := pq.NewConnector(dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *credentialConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+func (c *credentialConnector) dataSourceName(ctx context.Context) (string, error) {
+	credentials, err := c.credentials.Credentials(ctx)
+	if err != nil {
+		return "", fmt.Errorf("getting database credentials: %w", err)
+	}
+	params := c.connectionParams
+	if credentials.User != "" {
+		params.User = credentials.User
+	}
+	params.Password = credentials.Password
+	return params.DataSourceName(), nil
+}
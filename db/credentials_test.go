@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordFileCredentials(t *testing.T) {
+	ctx := context.Background()
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	testInstance := PasswordFileCredentials(passwordFile)
+
+	_, err := testInstance.Credentials(ctx)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	for _, password := range []string{"first", "second\n", "third \r\n", "fourth\nfifth"} {
+		require.NoError(t, os.WriteFile(passwordFile, []byte(password), 0o600))
+		res, err := testInstance.Credentials(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, Credentials{Password: strings.TrimRight(password, "\r\n")}, res)
+	}
+}
+
+func TestCredentialConnector_DataSourceName(t *testing.T) {
+	ctx := context.Background()
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	params := ConnectionParams{
+		Host:         "127.0.0.1",
+		User:         "bob",
+		Password:     "stale",
+		PasswordFile: passwordFile,
+		Port:         5433,
+		DB:           "bestiary",
+	}
+
+	testInstance := &credentialConnector{
+		connectionParams: params,
+		credentials:      PasswordFileCredentials(passwordFile),
+	}
+	require.NoError(t, os.WriteFile(passwordFile, []byte("first\n"), 0o600))
+	res, err := testInstance.dataSourceName(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "host=127.0.0.1 port=5433 user=bob password=first dbname=bestiary", res)
+
+	// rotating the password is picked up by the next connection
+	require.NoError(t, os.WriteFile(passwordFile, []byte("it's the second\n"), 0o600))
+	res, err = testInstance.dataSourceName(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, `host=127.0.0.1 port=5433 user=bob password='it\'s the second' dbname=bestiary`, res)
+
+	// providers handing out a user of their own take the place of the one in the params
+	testInstance.credentials = StaticCredentials{User: "alice", Password: "hunter2"}
+	res, err = testInstance.dataSourceName(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "host=127.0.0.1 port=5433 user=alice password=hunter2 dbname=bestiary", res)
+}
+
+// countingCredentials counts how many times it has been asked for credentials, failing every time
+type countingCredentials struct {
+	calls atomic.Int64
+}
+
+var errNoCredentials = errors.New("no credentials for you")
+
+func (c *countingCredentials) Credentials(_ context.Context) (Credentials, error) {
+	c.calls.Add(1)
+	return Credentials{}, errNoCredentials
+}
+
+func TestConnectionOpener_ConsultsCredentialsPerConnection(t *testing.T) {
+	ctx := context.Background()
+	credentials := &countingCredentials{}
+	testInstance := NewConnectionOpenerWithOptions(ConnectionParams{Host: "127.0.0.1", Port: 1}, ConnectionOpenerOptions{
+		Credentials: credentials,
+	})
+	defer testInstance.Close()
+
+	conn, err := testInstance.OpenConnection()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), credentials.calls.Load())
+
+	for i := 1; i <= 2; i++ {
+		err = conn.PingContext(ctx)
+		assert.ErrorIs(t, err, errNoCredentials)
+		assert.Equal(t, int64(i), credentials.calls.Load())
+	}
+}
+
+func TestConnectionOpener_PasswordRotation_Postgres(t *testing.T) {
+	ctx := context.Background()
+	connectionCfg, err := ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	adminOpener := NewConnectionOpener(connectionCfg)
+	defer adminOpener.Close()
+	admin, err := adminOpener.OpenConnection()
+	require.NoError(t, err)
+
+	role := fmt.Sprintf("creature_test_%s", strings.ReplaceAll(uuid.NewString(), "-", ""))
+	_, err = admin.ExecContext(ctx, fmt.Sprintf("create role %s login password 'first'", role))
+	require.NoError(t, err)
+	defer func() {
+		_, err := admin.ExecContext(ctx, fmt.Sprintf("drop role %s", role))
+		assert.NoError(t, err)
+	}()
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("first\n"), 0o600))
+	rotatingCfg := connectionCfg
+	rotatingCfg.User = role
+	rotatingCfg.Password = ""
+	rotatingCfg.PasswordFile = passwordFile
+	testInstance := NewConnectionOpener(rotatingCfg)
+	defer testInstance.Close()
+	conn, err := testInstance.OpenConnection()
+	require.NoError(t, err)
+	// with nothing kept idle every ping needs a new connection
+	conn.SetMaxIdleConns(0)
+	require.NoError(t, conn.PingContext(ctx))
+
+	_, err = admin.ExecContext(ctx, fmt.Sprintf("alter role %s password 'second'", role))
+	require.NoError(t, err)
+	assert.Error(t, conn.PingContext(ctx))
+
+	require.NoError(t, os.WriteFile(passwordFile, []byte("second\n"), 0o600))
+	assert.NoError(t, conn.PingContext(ctx))
+}
+
+func TestConnectionOpener_CurrentDataSourceName(t *testing.T) {
+	ctx := context.Background()
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("first\n"), 0o600))
+	testInstance := NewConnectionOpener(ConnectionParams{
+		Host:         "127.0.0.1",
+		User:         "bob",
+		Password:     "first",
+		PasswordFile: passwordFile,
+		Port:         5433,
+		DB:           "bestiary",
+	})
+
+	require.NoError(t, os.WriteFile(passwordFile, []byte("second\n"), 0o600))
+	res, err := testInstance.CurrentDataSourceName(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "host=127.0.0.1 port=5433 user=bob password=second dbname=bestiary", res)
+	// unlike DataSourceName, which sticks with the password as it was
+	assert.Equal(t, "host=127.0.0.1 port=5433 user=bob password=first dbname=bestiary", testInstance.DataSourceName())
+
+	require.NoError(t, os.Remove(passwordFile))
+	_, err = testInstance.CurrentDataSourceName(ctx)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -1,51 +1,214 @@
 package db
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"sync"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
-	_ "github.com/lib/pq"
 )
 
 type ConnectionParams struct {
 	Host     string
 	User     string
 	Password string
-	Port     int
-	DB       string
+	// PasswordFile is a file to read the password from. Connection openers re-read it whenever a new connection is made,
+	// so that the password can be rotated without a restart.
+	PasswordFile string
+	Port         int
+	DB           string
+
+	// SSLMode is passed to lib/pq as is, empty leaves lib/pq's default of require in place. SSLCert, SSLKey and SSLRootCert are paths to the client
+	// certificate, its key, and the root certificate to verify the server against.
+	SSLMode     string
+	SSLCert     string
+	SSLKey      string
+	SSLRootCert string
+	// ConnectTimeout bounds how long making a connection may take, rounded up to whole seconds. Zero means no limit.
+	ConnectTimeout  time.Duration
+	ApplicationName string
+	// SearchPath and StatementTimeout are set on every connection when non-zero, StatementTimeout being rounded up to
+	// whole milliseconds
+	SearchPath       string
+	StatementTimeout time.Duration
+	// ExtraParams are added to the connection string as they are, after everything else and in key order, for anything
+	// lib/pq understands, or passes on to the server, that isn't covered above, such as options. Settings that are
+	// covered above belong in their own fields.
+	ExtraParams map[string]string
+
+	// pool tuning, zero values leave the database/sql defaults in place
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
 }
 
+// ConnectionParamsFromEnv reads connection params from POSTGRES_ prefixed environment variables. A connection URL in
+// POSTGRES_URL, or failing that DATABASE_URL, takes the place of all the variables describing the connection, leaving
+// only pool tuning and POSTGRES_PW_FILE to be read from the others. POSTGRES_PW_FILE takes precedence over any other
+// password.
 func ConnectionParamsFromEnv() (ConnectionParams, error) {
 	dbCfg := struct {
-		Host     string `envconfig:"POSTGRES_HOST" default:"127.0.0.1"`
-		User     string `envconfig:"POSTGRES_USER" default:"postgres"`
-		Password string `envconfig:"POSTGRES_PW" default:"postgres"`
-		Port     int    `envconfig:"POSTGRES_PORT" default:"5433"`
-		DB       string `envconfig:"POSTGRES_DB" default:"postgres"`
+		URL              string        `envconfig:"POSTGRES_URL"`
+		DatabaseURL      string        `envconfig:"DATABASE_URL"`
+		Host             string        `envconfig:"POSTGRES_HOST" default:"127.0.0.1"`
+		User             string        `envconfig:"POSTGRES_USER" default:"postgres"`
+		Password         string        `envconfig:"POSTGRES_PW" default:"postgres"`
+		PasswordFile     string        `envconfig:"POSTGRES_PW_FILE"`
+		Port             int           `envconfig:"POSTGRES_PORT" default:"5433"`
+		DB               string        `envconfig:"POSTGRES_DB" default:"postgres"`
+		SSLMode          string        `envconfig:"POSTGRES_SSL_MODE" default:"disable"`
+		SSLCert          string        `envconfig:"POSTGRES_SSL_CERT"`
+		SSLKey           string        `envconfig:"POSTGRES_SSL_KEY"`
+		SSLRootCert      string        `envconfig:"POSTGRES_SSL_ROOT_CERT"`
+		ConnectTimeout   time.Duration `envconfig:"POSTGRES_CONNECT_TIMEOUT"`
+		ApplicationName  string        `envconfig:"POSTGRES_APPLICATION_NAME"`
+		SearchPath       string        `envconfig:"POSTGRES_SEARCH_PATH"`
+		StatementTimeout time.Duration `envconfig:"POSTGRES_STATEMENT_TIMEOUT"`
+		MaxOpenConns     int           `envconfig:"POSTGRES_MAX_OPEN_CONNS" default:"10"`
+		MaxIdleConns     int           `envconfig:"POSTGRES_MAX_IDLE_CONNS" default:"5"`
+		ConnMaxLifetime  time.Duration `envconfig:"POSTGRES_CONN_MAX_LIFETIME" default:"30m"`
+		ConnMaxIdleTime  time.Duration `envconfig:"POSTGRES_CONN_MAX_IDLE_TIME" default:"5m"`
 	}{}
 	err := envconfig.Process("", &dbCfg)
 	if err != nil {
 		return ConnectionParams{}, err
 	}
-	return ConnectionParams{
-		Host:     dbCfg.Host,
-		User:     dbCfg.User,
-		Password: dbCfg.Password,
-		Port:     dbCfg.Port,
-		DB:       dbCfg.DB,
-	}, nil
+	ret := ConnectionParams{
+		Host:             dbCfg.Host,
+		User:             dbCfg.User,
+		Password:         dbCfg.Password,
+		Port:             dbCfg.Port,
+		DB:               dbCfg.DB,
+		SSLMode:          dbCfg.SSLMode,
+		SSLCert:          dbCfg.SSLCert,
+		SSLKey:           dbCfg.SSLKey,
+		SSLRootCert:      dbCfg.SSLRootCert,
+		ConnectTimeout:   dbCfg.ConnectTimeout,
+		ApplicationName:  dbCfg.ApplicationName,
+		SearchPath:       dbCfg.SearchPath,
+		StatementTimeout: dbCfg.StatementTimeout,
+	}
+	connectionURL := dbCfg.URL
+	if connectionURL == "" {
+		connectionURL = dbCfg.DatabaseURL
+	}
+	if connectionURL != "" {
+		ret, err = ParseConnectionURL(connectionURL)
+		if err != nil {
+			return ConnectionParams{}, err
+		}
+	}
+	if dbCfg.PasswordFile != "" {
+		// the file is read up front so that a missing file is noticed straight away, and DataSourceName has a password
+		ret.PasswordFile = dbCfg.PasswordFile
+		ret.Password, err = readPasswordFile(dbCfg.PasswordFile)
+		if err != nil {
+			return ConnectionParams{}, err
+		}
+	}
+	ret.MaxOpenConns = dbCfg.MaxOpenConns
+	ret.MaxIdleConns = dbCfg.MaxIdleConns
+	ret.ConnMaxLifetime = dbCfg.ConnMaxLifetime
+	ret.ConnMaxIdleTime = dbCfg.ConnMaxIdleTime
+	return ret, nil
 }
 
+// ConnectionOpener owns a single connection pool for its lifetime. The pool is created on the first call to
+// OpenConnection and every subsequent call hands back the same *sql.DB, so callers must not close it - use Close on the
+// opener during shutdown instead.
 type ConnectionOpener struct {
 	connectionParams ConnectionParams
+	credentials      CredentialProvider
+
+	db      *sql.DB
+	dbMutex sync.Mutex
+}
+
+type ConnectionOpenerOptions struct {
+	// Credentials is consulted for the credentials to use whenever a new connection is made. Nil means
+	// PasswordFileCredentials when the connection params have a PasswordFile, and the user and password from the
+	// connection params otherwise.
+	Credentials CredentialProvider
 }
 
 func NewConnectionOpener(connectionParams ConnectionParams) *ConnectionOpener {
-	return &ConnectionOpener{connectionParams: connectionParams}
+	return NewConnectionOpenerWithOptions(connectionParams, ConnectionOpenerOptions{})
+}
+
+func NewConnectionOpenerWithOptions(connectionParams ConnectionParams, options ConnectionOpenerOptions) *ConnectionOpener {
+	credentials := options.Credentials
+	if credentials == nil {
+		if connectionParams.PasswordFile != "" {
+			credentials = PasswordFileCredentials(connectionParams.PasswordFile)
+		} else {
+			credentials = StaticCredentials{
+				User:     connectionParams.User,
+				Password: connectionParams.Password,
+			}
+		}
+	}
+	return &ConnectionOpener{
+		connectionParams: connectionParams,
+		credentials:      credentials,
+	}
 }
 
 func (c *ConnectionOpener) OpenConnection() (*sql.DB, error) {
-	psqlconn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", c.connectionParams.Host, c.connectionParams.Port, c.connectionParams.User, c.connectionParams.Password, c.connectionParams.User)
-	return sql.Open("postgres", psqlconn)
+	c.dbMutex.Lock()
+	defer c.dbMutex.Unlock()
+	if c.db != nil {
+		return c.db, nil
+	}
+	db := sql.OpenDB(&credentialConnector{
+		connectionParams: c.connectionParams,
+		credentials:      c.credentials,
+	})
+	c.configurePool(db)
+	c.db = db
+	return db, nil
+}
+
+func (c *ConnectionOpener) Close() error {
+	c.dbMutex.Lock()
+	defer c.dbMutex.Unlock()
+	if c.db == nil {
+		return nil
+	}
+	err := c.db.Close()
+	c.db = nil
+	return err
+}
+
+func (c *ConnectionOpener) configurePool(db *sql.DB) {
+	if c.connectionParams.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(c.connectionParams.MaxOpenConns)
+	}
+	if c.connectionParams.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(c.connectionParams.MaxIdleConns)
+	}
+	if c.connectionParams.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(c.connectionParams.ConnMaxLifetime)
+	}
+	if c.connectionParams.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(c.connectionParams.ConnMaxIdleTime)
+	}
+}
+
+// DataSourceName is the lib/pq connection string for the database, for the likes of pq.Listener that need to manage
+// connections of their own. It holds the credentials from the connection params, so unlike connections made by the
+// opener, connections made with it don't pick up rotated credentials, see CurrentDataSourceName for that.
+func (c *ConnectionOpener) DataSourceName() string {
+	return c.connectionParams.DataSourceName()
+}
+
+// CurrentDataSourceName is the lib/pq connection string for the database holding the credentials the opener's
+// CredentialProvider hands out right now
+func (c *ConnectionOpener) CurrentDataSourceName(ctx context.Context) (string, error) {
+	connector := &credentialConnector{
+		connectionParams: c.connectionParams,
+		credentials:      c.credentials,
+	}
+	return connector.dataSourceName(ctx)
 }
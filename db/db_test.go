@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionOpener_OpenConnection_SharesPool(t *testing.T) {
+	connectionCfg, err := ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	testInstance := NewConnectionOpener(connectionCfg)
+
+	first, err := testInstance.OpenConnection()
+	require.NoError(t, err)
+	second, err := testInstance.OpenConnection()
+	require.NoError(t, err)
+	require.Same(t, first, second)
+
+	require.NoError(t, testInstance.Close())
+	// closing twice should be harmless, and a closed opener should hand out a fresh pool
+	require.NoError(t, testInstance.Close())
+	third, err := testInstance.OpenConnection()
+	require.NoError(t, err)
+	require.NotSame(t, first, third)
+	require.NoError(t, testInstance.Close())
+}
+
+// the two benchmarks below perform the same lookup, one against a fresh pool every iteration (as things used to work)
+// and one against the shared pool. Run with `go test -bench=. ./db` against the docker postgres to compare.
+
+func BenchmarkLookup_OpenPerCall(b *testing.B) {
+	ctx := context.Background()
+	connectionCfg, err := ConnectionParamsFromEnv()
+	require.NoError(b, err)
+	connectionOpener := NewConnectionOpener(connectionCfg)
+	id := insertBenchmarkCreature(b, connectionOpener)
+	defer connectionOpener.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn, err := sql.Open("postgres", connectionOpener.DataSourceName())
+		require.NoError(b, err)
+		lookupBenchmarkCreature(ctx, b, conn, id)
+		require.NoError(b, conn.Close())
+	}
+}
+
+func BenchmarkLookup_SharedPool(b *testing.B) {
+	ctx := context.Background()
+	connectionCfg, err := ConnectionParamsFromEnv()
+	require.NoError(b, err)
+	connectionOpener := NewConnectionOpener(connectionCfg)
+	id := insertBenchmarkCreature(b, connectionOpener)
+	defer connectionOpener.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn, err := connectionOpener.OpenConnection()
+		require.NoError(b, err)
+		lookupBenchmarkCreature(ctx, b, conn, id)
+	}
+}
+
+func insertBenchmarkCreature(b *testing.B, connectionOpener *ConnectionOpener) int64 {
+	conn, err := connectionOpener.OpenConnection()
+	require.NoError(b, err)
+	row := conn.QueryRow("insert into creatures (name, description) values ($1, $2) returning id", fmt.Sprintf("creature_bench_%s", uuid.NewString()), "a creature for benchmarking purposes")
+	var id int64
+	require.NoError(b, row.Scan(&id))
+	return id
+}
+
+func lookupBenchmarkCreature(ctx context.Context, b *testing.B, conn *sql.DB, id int64) {
+	stmt, err := conn.PrepareContext(ctx, "select name, description from creatures where id=$1")
+	require.NoError(b, err)
+	defer stmt.Close()
+	var name, description string
+	require.NoError(b, stmt.QueryRowContext(ctx, id).Scan(&name, &description))
+}
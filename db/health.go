@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Opener is anything handing out a connection pool, such as a ConnectionOpener or RoutingConnectionOpener
+type Opener interface {
+	OpenConnection() (*sql.DB, error)
+}
+
+type HealthCheckerOptions struct {
+	// Timeout bounds each check, zero means DefaultHealthCheckTimeout
+	Timeout time.Duration
+	// MigrationVersion is the version schema_migrations must be at for the database to be ready. Zero accepts any
+	// version, so long as migrations have been run and none were left part way through.
+	MigrationVersion int64
+	// DetailedErrors has Handler respond with the error that made a check fail, rather than a generic reason. The error
+	// comes from the driver as is, and can give away hosts, users and the like, so should only be enabled where the
+	// handler can't be reached by just anyone.
+	DetailedErrors bool
+}
+
+// PoolStats is a summary of sql.DBStats
+type PoolStats struct {
+	MaxOpenConnections int     `json:"max_open_connections"`
+	OpenConnections    int     `json:"open_connections"`
+	InUse              int     `json:"in_use"`
+	Idle               int     `json:"idle"`
+	WaitCount          int64   `json:"wait_count"`
+	WaitSeconds        float64 `json:"wait_seconds"`
+}
+
+// Generic reasons for failed health checks, which Handler responds with in place of the errors behind them
+const (
+	HealthReasonUnreachable      = "database unreachable"
+	HealthReasonMigrationUnknown = "migration version could not be checked"
+	HealthReasonMigrations       = "migrations not at expected version"
+)
+
+// HealthReport is the outcome of a health check, which is also what the health check handler responds with. Unless
+// HealthCheckerOptions.DetailedErrors is set, the handler's Error is one of the generic HealthReason values.
+type HealthReport struct {
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+	// MigrationVersion is the version schema_migrations is at, which is only looked up by readiness checks
+	MigrationVersion int64     `json:"migration_version,omitempty"`
+	Pool             PoolStats `json:"pool"`
+}
+
+// HealthChecker checks whether the database can be reached, and whether it has been migrated far enough to be used
+type HealthChecker struct {
+	connectionOpener Opener
+	timeout          time.Duration
+	migrationVersion int64
+	detailedErrors   bool
+}
+
+func NewHealthChecker(connectionOpener Opener) *HealthChecker {
+	return NewHealthCheckerWithOptions(connectionOpener, HealthCheckerOptions{})
+}
+
+func NewHealthCheckerWithOptions(connectionOpener Opener, options HealthCheckerOptions) *HealthChecker {
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+	return &HealthChecker{
+		connectionOpener: connectionOpener,
+		timeout:          timeout,
+		migrationVersion: options.MigrationVersion,
+		detailedErrors:   options.DetailedErrors,
+	}
+}
+
+// Live pings the database
+func (h *HealthChecker) Live(ctx context.Context) HealthReport {
+	report, _ := h.check(ctx, false)
+	return report
+}
+
+// Ready pings the database and checks that it is at the expected migration version
+func (h *HealthChecker) Ready(ctx context.Context) HealthReport {
+	report, _ := h.check(ctx, true)
+	return report
+}
+
+// Handler serves Live at /healthz and Ready at /readyz, responding with the report as JSON, and a 503 status if
+// unhealthy. As the handler is usually reachable without authentication, failures are reported with a generic reason
+// rather than the error behind them, unless HealthCheckerOptions.DetailedErrors is set.
+func (h *HealthChecker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, r, false)
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, r, true)
+	})
+	return mux
+}
+
+func (h *HealthChecker) serve(w http.ResponseWriter, r *http.Request, checkMigrations bool) {
+	report, reason := h.check(r.Context(), checkMigrations)
+	if !report.Healthy && !h.detailedErrors {
+		report.Error = reason
+	}
+	writeHealthReport(w, report)
+}
+
+// check runs a health check, returning a generic reason for it failing alongside the report
+func (h *HealthChecker) check(ctx context.Context, checkMigrations bool) (HealthReport, string) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	conn, err := h.connectionOpener.OpenConnection()
+	if err != nil {
+		return HealthReport{Error: err.Error()}, HealthReasonUnreachable
+	}
+
+	var ret HealthReport
+	reason := HealthReasonUnreachable
+	err = conn.PingContext(ctx)
+	if err == nil && checkMigrations {
+		ret.MigrationVersion, reason, err = h.checkMigrationVersion(ctx, conn)
+	}
+	ret.Pool = poolStats(conn.Stats())
+	if err != nil {
+		ret.Error = err.Error()
+		return ret, reason
+	}
+	ret.Healthy = true
+	return ret, ""
+}
+
+// checkMigrationVersion returns the version schema_migrations is at, along with a generic reason for the error if the
+// version isn't usable
+func (h *HealthChecker) checkMigrationVersion(ctx context.Context, conn *sql.DB) (int64, string, error) {
+	var version int64
+	var dirty bool
+	err := conn.QueryRowContext(ctx, "select version, dirty from schema_migrations limit 1").Scan(&version, &dirty)
+	if err != nil {
+		if isUndefinedTable(err) {
+			return 0, HealthReasonMigrations, errors.New("schema_migrations not found, the database has not been migrated")
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, HealthReasonMigrations, errors.New("no migrations have been applied")
+		}
+		return 0, HealthReasonMigrationUnknown, fmt.Errorf("checking migration version: %w", err)
+	}
+	if dirty {
+		return version, HealthReasonMigrations, fmt.Errorf("migration version %d was left dirty by a failed migration", version)
+	}
+	if h.migrationVersion != 0 && version != h.migrationVersion {
+		return version, HealthReasonMigrations, fmt.Errorf("database is at migration version %d, expected version %d", version, h.migrationVersion)
+	}
+	return version, "", nil
+}
+
+func poolStats(stats sql.DBStats) PoolStats {
+	return PoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitSeconds:        stats.WaitDuration.Seconds(),
+	}
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
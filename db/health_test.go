@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthChecker_Unreachable(t *testing.T) {
+	ctx := context.Background()
+	connectionOpener := NewConnectionOpener(unreachableParams)
+	defer connectionOpener.Close()
+	testInstance := NewHealthChecker(connectionOpener)
+
+	for _, report := range []HealthReport{testInstance.Live(ctx), testInstance.Ready(ctx)} {
+		assert.False(t, report.Healthy)
+		assert.Contains(t, report.Error, "connection refused")
+	}
+
+	// the handler only gives a generic reason, the error itself could give away where the database is
+	handler := testInstance.Handler()
+	for _, path := range []string{"/healthz", "/readyz"} {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusServiceUnavailable, res.Code, path)
+		assert.Equal(t, "application/json", res.Header().Get("Content-Type"))
+		var report HealthReport
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&report))
+		assert.False(t, report.Healthy)
+		assert.Equal(t, HealthReasonUnreachable, report.Error)
+	}
+
+	// unless asked for details
+	detailedHandler := NewHealthCheckerWithOptions(connectionOpener, HealthCheckerOptions{DetailedErrors: true}).Handler()
+	for _, path := range []string{"/healthz", "/readyz"} {
+		res := httptest.NewRecorder()
+		detailedHandler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusServiceUnavailable, res.Code, path)
+		var report HealthReport
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&report))
+		assert.Contains(t, report.Error, "connection refused")
+	}
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/nope", nil))
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestHealthChecker_Postgres(t *testing.T) {
+	ctx := context.Background()
+	connectionCfg, err := ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	adminOpener := NewConnectionOpener(connectionCfg)
+	defer adminOpener.Close()
+	admin, err := adminOpener.OpenConnection()
+	require.NoError(t, err)
+
+	// every case gets a schema of its own, so schema_migrations can be set up as needed without touching the real one
+	testCases := []struct {
+		name             string
+		setup            string
+		migrationVersion int64
+		expectedVersion  int64
+		expectedError    string
+	}{
+		{
+			name:          "not migrated",
+			expectedError: "schema_migrations not found",
+		},
+		{
+			name:          "no migrations applied",
+			setup:         "create table schema_migrations (version bigint not null primary key, dirty boolean not null)",
+			expectedError: "no migrations have been applied",
+		},
+		{
+			name:            "dirty",
+			setup:           "create table schema_migrations (version bigint not null primary key, dirty boolean not null); insert into schema_migrations values (2, true)",
+			expectedVersion: 2,
+			expectedError:   "migration version 2 was left dirty",
+		},
+		{
+			name:             "unexpected version",
+			setup:            "create table schema_migrations (version bigint not null primary key, dirty boolean not null); insert into schema_migrations values (1, false)",
+			migrationVersion: 2,
+			expectedVersion:  1,
+			expectedError:    "database is at migration version 1, expected version 2",
+		},
+		{
+			name:             "expected version",
+			setup:            "create table schema_migrations (version bigint not null primary key, dirty boolean not null); insert into schema_migrations values (2, false)",
+			migrationVersion: 2,
+			expectedVersion:  2,
+		},
+		{
+			name:            "any version",
+			setup:           "create table schema_migrations (version bigint not null primary key, dirty boolean not null); insert into schema_migrations values (2, false)",
+			expectedVersion: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			schema := fmt.Sprintf("health_test_%s", strings.ReplaceAll(uuid.NewString(), "-", ""))
+			_, err := admin.ExecContext(ctx, fmt.Sprintf("create schema %s", schema))
+			require.NoError(t, err)
+			defer func() {
+				_, err := admin.ExecContext(ctx, fmt.Sprintf("drop schema %s cascade", schema))
+				assert.NoError(t, err)
+			}()
+			schemaCfg := connectionCfg
+			schemaCfg.SearchPath = schema
+			connectionOpener := NewConnectionOpener(schemaCfg)
+			defer connectionOpener.Close()
+			if tc.setup != "" {
+				conn, err := connectionOpener.OpenConnection()
+				require.NoError(t, err)
+				_, err = conn.ExecContext(ctx, tc.setup)
+				require.NoError(t, err)
+			}
+
+			testInstance := NewHealthCheckerWithOptions(connectionOpener, HealthCheckerOptions{
+				MigrationVersion: tc.migrationVersion,
+			})
+			live := testInstance.Live(ctx)
+			assert.Equal(t, HealthReport{Healthy: true, Pool: live.Pool}, live)
+			assert.Positive(t, live.Pool.OpenConnections)
+
+			ready := testInstance.Ready(ctx)
+			assert.Equal(t, tc.expectedError == "", ready.Healthy)
+			assert.Contains(t, ready.Error, tc.expectedError)
+			assert.Equal(t, tc.expectedVersion, ready.MigrationVersion)
+
+			expectedStatus := http.StatusOK
+			if tc.expectedError != "" {
+				expectedStatus = http.StatusServiceUnavailable
+			}
+			res := httptest.NewRecorder()
+			testInstance.Handler().ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, expectedStatus, res.Code)
+			if tc.expectedError != "" {
+				var report HealthReport
+				require.NoError(t, json.NewDecoder(res.Body).Decode(&report))
+				assert.Equal(t, HealthReasonMigrations, report.Error)
+			}
+			res = httptest.NewRecorder()
+			testInstance.Handler().ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			assert.Equal(t, http.StatusOK, res.Code)
+		})
+	}
+}
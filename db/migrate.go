@@ -0,0 +1,339 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// migrationLockID is the key of the advisory lock held while migrating, so that concurrent runners take turns
+const migrationLockID = 7_342_811_954
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// ErrDirtyMigration is returned when a migration was left part way through, which needs sorting out by hand and then
+// Force
+var ErrDirtyMigration = errors.New("dirty migration")
+
+// Migration is a single step of the schema, from the version before it to its own
+type Migration struct {
+	Version int64
+	Name    string
+	// Applied is set in a MigrationStatus for migrations the database has been migrated past
+	Applied bool
+
+	up   string
+	down string
+}
+
+type MigrationStatus struct {
+	// Version is the version the database is at, zero if no migrations have been applied
+	Version int64
+	Dirty   bool
+	// Migrations lists every known migration, oldest first
+	Migrations []Migration
+}
+
+type MigratorOptions struct {
+	// Logger receives each migration as it is applied or rolled back, nil means slog.Default()
+	Logger *slog.Logger
+}
+
+// Migrator applies and rolls back migrations read from {version}_{description}.{up|down}.sql files, such as those in
+// migrations.FS, tracking the version reached in the schema_migrations table. The table is laid out the same as the
+// golang-migrate CLI lays it out, so databases migrated with it can be carried on with.
+//
+// Every migration runs in a transaction along with the version update, so a failed migration leaves the database at
+// the version before it. Runners hold an advisory lock while migrating, so any number of them can be started at once.
+type Migrator struct {
+	connectionOpener Opener
+	source           fs.FS
+	logger           *slog.Logger
+}
+
+func NewMigrator(connectionOpener Opener, source fs.FS) *Migrator {
+	return NewMigratorWithOptions(connectionOpener, source, MigratorOptions{})
+}
+
+func NewMigratorWithOptions(connectionOpener Opener, source fs.FS, options MigratorOptions) *Migrator {
+	logger := options.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Migrator{
+		connectionOpener: connectionOpener,
+		source:           source,
+		logger:           logger,
+	}
+}
+
+// Up applies every migration not yet applied
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.migrate(ctx, func(migrations []Migration, _ int64) (int64, error) {
+		if len(migrations) == 0 {
+			return 0, nil
+		}
+		return migrations[len(migrations)-1].Version, nil
+	})
+}
+
+// Down rolls back the latest migration applied
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.migrate(ctx, func(migrations []Migration, current int64) (int64, error) {
+		if current == 0 {
+			return 0, nil
+		}
+		i := slices.IndexFunc(migrations, func(migration Migration) bool {
+			return migration.Version == current
+		})
+		if i <= 0 {
+			return 0, nil
+		}
+		return migrations[i-1].Version, nil
+	})
+}
+
+// To applies or rolls back migrations until the database is at version, zero rolling back every migration
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	return m.migrate(ctx, func(migrations []Migration, _ int64) (int64, error) {
+		if version != 0 && !slices.ContainsFunc(migrations, func(migration Migration) bool {
+			return migration.Version == version
+		}) {
+			return 0, fmt.Errorf("no migration with version %d", version)
+		}
+		return version, nil
+	})
+}
+
+// Force records the database as being at version, clean, without running any migrations. It is for after a migration
+// left dirty has been finished or undone by hand.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if err := setMigrationVersion(ctx, tx, version); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// Status reports the version the database is at, and which migrations have been applied
+func (m *Migrator) Status(ctx context.Context) (MigrationStatus, error) {
+	migrations, err := m.migrations()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	db, err := m.connectionOpener.OpenConnection()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	defer conn.Close()
+	version, dirty, err := migrationVersion(ctx, conn)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	for i := range migrations {
+		migrations[i].Applied = migrations[i].Version <= version
+	}
+	return MigrationStatus{
+		Version:    version,
+		Dirty:      dirty,
+		Migrations: migrations,
+	}, nil
+}
+
+// migrate takes the database from the version it is at to the one picked by target
+func (m *Migrator) migrate(ctx context.Context, target func(migrations []Migration, current int64) (int64, error)) error {
+	migrations, err := m.migrations()
+	if err != nil {
+		return err
+	}
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		current, dirty, err := migrationVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("%w: version %d", ErrDirtyMigration, current)
+		}
+		if current != 0 && !slices.ContainsFunc(migrations, func(migration Migration) bool {
+			return migration.Version == current
+		}) {
+			return fmt.Errorf("database is at version %d, which there is no migration for", current)
+		}
+		version, err := target(migrations, current)
+		if err != nil {
+			return err
+		}
+
+		if version > current {
+			for _, migration := range migrations {
+				if migration.Version > current && migration.Version <= version {
+					if err := m.apply(ctx, conn, migration, "up", migration.up, migration.Version); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		for i := len(migrations) - 1; i >= 0 && version < current; i-- {
+			migration := migrations[i]
+			if migration.Version > version && migration.Version <= current {
+				if migration.down == "" {
+					return fmt.Errorf("migration %d has no down migration", migration.Version)
+				}
+				previous := int64(0)
+				if i > 0 {
+					previous = migrations[i-1].Version
+				}
+				if err := m.apply(ctx, conn, migration, "down", migration.down, previous); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, direction, statements string, version int64) error {
+	m.logger.InfoContext(ctx, "migrating", "version", migration.Version, "name", migration.Name, "direction", direction)
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return fmt.Errorf("migration %d %s: %w", migration.Version, direction, err)
+	}
+	if err := setMigrationVersion(ctx, tx, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// withLock runs f holding the migration lock, on a connection set aside for the purpose as advisory locks belong to the
+// connection taking them
+func (m *Migrator) withLock(ctx context.Context, f func(conn *sql.Conn) error) error {
+	db, err := m.connectionOpener.OpenConnection()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "select pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("taking migration lock: %w", err)
+	}
+	_, err = conn.ExecContext(ctx, "create table if not exists schema_migrations (version bigint not null primary key, dirty boolean not null)")
+	if err == nil {
+		err = f(conn)
+	} else {
+		err = fmt.Errorf("creating schema_migrations: %w", err)
+	}
+	if _, unlockErr := conn.ExecContext(context.WithoutCancel(ctx), "select pg_advisory_unlock($1)", migrationLockID); unlockErr != nil {
+		err = errors.Join(err, fmt.Errorf("releasing migration lock: %w", unlockErr))
+	}
+	return err
+}
+
+// migrations reads the migrations from the source, oldest first
+func (m *Migrator) migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(m.source, ".")
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s has an invalid version", entry.Name())
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", migration.Name, match[2], version)
+		}
+		raw, err := fs.ReadFile(m.source, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %s: %w", entry.Name(), err)
+		}
+		if match[3] == "up" {
+			migration.up = string(raw)
+		} else {
+			migration.down = string(raw)
+		}
+	}
+
+	ret := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" {
+			return nil, fmt.Errorf("migration %d has no up migration", migration.Version)
+		}
+		ret = append(ret, *migration)
+	}
+	slices.SortFunc(ret, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return ret, nil
+}
+
+// migrationVersion looks up the version the database is at, which is zero if it has never been migrated
+func migrationVersion(ctx context.Context, conn *sql.Conn) (int64, bool, error) {
+	var version int64
+	var dirty bool
+	err := conn.QueryRowContext(ctx, "select version, dirty from schema_migrations limit 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) || isUndefinedTable(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("reading schema_migrations: %w", err)
+	}
+	return version, dirty, nil
+}
+
+func setMigrationVersion(ctx context.Context, tx *sql.Tx, version int64) error {
+	if _, err := tx.ExecContext(ctx, "delete from schema_migrations"); err != nil {
+		return fmt.Errorf("updating schema_migrations: %w", err)
+	}
+	if version == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, "insert into schema_migrations (version, dirty) values ($1, false)", version); err != nil {
+		return fmt.Errorf("updating schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// isUndefinedTable reports whether err is postgres complaining about a table that doesn't exist
+func isUndefinedTable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "42P01"
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/uuid"
+	"github.com/jonsabados/srp-sample/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrator_migrations(t *testing.T) {
+	testCases := []struct {
+		name          string
+		source        fstest.MapFS
+		expected      []Migration
+		expectedError string
+	}{
+		{
+			name: "ordered by version",
+			source: fstest.MapFS{
+				"10_later.up.sql":     {Data: []byte("later up")},
+				"2_sooner.up.sql":     {Data: []byte("sooner up")},
+				"2_sooner.down.sql":   {Data: []byte("sooner down")},
+				"0001_first.up.sql":   {Data: []byte("first up")},
+				"0001_first.down.sql": {Data: []byte("first down")},
+				"README.md":           {Data: []byte("not a migration")},
+			},
+			expected: []Migration{
+				{Version: 1, Name: "first", up: "first up", down: "first down"},
+				{Version: 2, Name: "sooner", up: "sooner up", down: "sooner down"},
+				{Version: 10, Name: "later", up: "later up"},
+			},
+		},
+		{
+			name:     "none",
+			source:   fstest.MapFS{},
+			expected: []Migration{},
+		},
+		{
+			name: "missing up",
+			source: fstest.MapFS{
+				"0001_first.down.sql": {Data: []byte("first down")},
+			},
+			expectedError: "migration 1 has no up migration",
+		},
+		{
+			name: "shared version",
+			source: fstest.MapFS{
+				"0001_first.up.sql":  {Data: []byte("first up")},
+				"0001_second.up.sql": {Data: []byte("second up")},
+			},
+			expectedError: "share version 1",
+		},
+		{
+			name: "version zero",
+			source: fstest.MapFS{
+				"0000_nothing.up.sql": {Data: []byte("nothing up")},
+			},
+			expectedError: "invalid version",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := NewMigrator(nil, tc.source).migrations()
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, res)
+			}
+		})
+	}
+}
+
+// newTestSchema creates a schema that is dropped once the test is done, returning connection params that use it
+func newTestSchema(t *testing.T) ConnectionParams {
+	ctx := context.Background()
+	connectionCfg, err := ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	adminOpener := NewConnectionOpener(connectionCfg)
+	admin, err := adminOpener.OpenConnection()
+	require.NoError(t, err)
+
+	schema := fmt.Sprintf("migrate_test_%s", strings.ReplaceAll(uuid.NewString(), "-", ""))
+	_, err = admin.ExecContext(ctx, fmt.Sprintf("create schema %s", schema))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := admin.ExecContext(ctx, fmt.Sprintf("drop schema %s cascade", schema))
+		assert.NoError(t, err)
+		assert.NoError(t, adminOpener.Close())
+	})
+	connectionCfg.SearchPath = schema
+	return connectionCfg
+}
+
+func TestMigrator_Postgres(t *testing.T) {
+	ctx := context.Background()
+	connectionOpener := NewConnectionOpener(newTestSchema(t))
+	defer connectionOpener.Close()
+	conn, err := connectionOpener.OpenConnection()
+	require.NoError(t, err)
+	testInstance := NewMigrator(connectionOpener, migrations.FS)
+
+	assertState := func(expectedVersion int64, creaturesExist, triggerExists bool) {
+		t.Helper()
+		status, err := testInstance.Status(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, expectedVersion, status.Version)
+		assert.False(t, status.Dirty)
+		require.Len(t, status.Migrations, 2)
+		for _, migration := range status.Migrations {
+			assert.Equal(t, migration.Version <= expectedVersion, migration.Applied, "migration %d", migration.Version)
+		}
+
+		var creatures, trigger bool
+		row := conn.QueryRowContext(ctx, "select to_regclass('creatures') is not null, to_regproc('notify_creature_change') is not null")
+		require.NoError(t, row.Scan(&creatures, &trigger))
+		assert.Equal(t, creaturesExist, creatures, "creatures table exists")
+		assert.Equal(t, triggerExists, trigger, "trigger function exists")
+	}
+
+	assertState(0, false, false)
+
+	require.NoError(t, testInstance.Up(ctx))
+	assertState(2, true, true)
+	_, err = conn.ExecContext(ctx, "insert into creatures (name, description) values ('bob', 'likes testing')")
+	require.NoError(t, err)
+	// nothing left to apply
+	require.NoError(t, testInstance.Up(ctx))
+	assertState(2, true, true)
+
+	require.NoError(t, testInstance.Down(ctx))
+	assertState(1, true, false)
+	require.NoError(t, testInstance.Down(ctx))
+	assertState(0, false, false)
+	// nothing left to roll back
+	require.NoError(t, testInstance.Down(ctx))
+	assertState(0, false, false)
+
+	require.NoError(t, testInstance.To(ctx, 1))
+	assertState(1, true, false)
+	require.NoError(t, testInstance.To(ctx, 2))
+	assertState(2, true, true)
+	assert.ErrorContains(t, testInstance.To(ctx, 3), "no migration with version 3")
+	assertState(2, true, true)
+	require.NoError(t, testInstance.To(ctx, 0))
+	assertState(0, false, false)
+}
+
+func TestMigrator_Concurrency_Postgres(t *testing.T) {
+	ctx := context.Background()
+	connectionCfg := newTestSchema(t)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			connectionOpener := NewConnectionOpener(connectionCfg)
+			defer connectionOpener.Close()
+			assert.NoError(t, NewMigrator(connectionOpener, migrations.FS).Up(ctx))
+		}()
+	}
+	wg.Wait()
+
+	connectionOpener := NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+	status, err := NewMigrator(connectionOpener, migrations.FS).Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), status.Version)
+}
+
+func TestMigrator_Failures_Postgres(t *testing.T) {
+	ctx := context.Background()
+	connectionOpener := NewConnectionOpener(newTestSchema(t))
+	defer connectionOpener.Close()
+	conn, err := connectionOpener.OpenConnection()
+	require.NoError(t, err)
+	testInstance := NewMigrator(connectionOpener, fstest.MapFS{
+		"0001_first.up.sql":  {Data: []byte("create table first (id bigint)")},
+		"0002_broken.up.sql": {Data: []byte("create table second (id bigint); this is not sql")},
+	})
+
+	// the broken migration is rolled back in full, leaving the database clean at the version before it
+	assert.ErrorContains(t, testInstance.Up(ctx), "migration 2 up")
+	status, err := testInstance.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), status.Version)
+	assert.False(t, status.Dirty)
+	var second bool
+	require.NoError(t, conn.QueryRowContext(ctx, "select to_regclass('second') is not null").Scan(&second))
+	assert.False(t, second)
+
+	// there is no rolling back without a down migration
+	assert.ErrorContains(t, testInstance.Down(ctx), "migration 1 has no down migration")
+
+	// migrations left dirty, such as by the migrate CLI, need forcing before anything else is done
+	_, err = conn.ExecContext(ctx, "update schema_migrations set dirty = true")
+	require.NoError(t, err)
+	assert.ErrorIs(t, testInstance.Up(ctx), ErrDirtyMigration)
+	require.NoError(t, testInstance.Force(ctx, 1))
+	status, err = testInstance.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, MigrationStatus{
+		Version: 1,
+		Migrations: []Migration{
+			{Version: 1, Name: "first", Applied: true, up: "create table first (id bigint)"},
+			{Version: 2, Name: "broken", up: "create table second (id bigint); this is not sql"},
+		},
+	}, status)
+}
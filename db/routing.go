@@ -0,0 +1,226 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaSelection decides which healthy replica a RoutingConnectionOpener sends a read to
+type ReplicaSelection int
+
+const (
+	// RoundRobin takes turns between the healthy replicas
+	RoundRobin ReplicaSelection = iota
+	// LeastLatency picks the healthy replica that answered its last health check the quickest
+	LeastLatency
+)
+
+const (
+	DefaultHealthCheckInterval = 5 * time.Second
+	DefaultHealthCheckTimeout  = time.Second
+)
+
+type readYourWritesKey struct{}
+
+// WithReadYourWrites returns a context that sends reads made with it to the primary, for reading back something just
+// written before the replicas could have caught up with it
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readYourWritesKey{}, true)
+}
+
+func readYourWrites(ctx context.Context) bool {
+	ret, _ := ctx.Value(readYourWritesKey{}).(bool)
+	return ret
+}
+
+// RoutingConnectionOpenerOptions allows tuning of a RoutingConnectionOpener, zero values fall back to the package defaults
+type RoutingConnectionOpenerOptions struct {
+	Selection           ReplicaSelection
+	HealthCheckInterval time.Duration
+	// HealthCheckTimeout bounds how long a replica has to answer a health check before it is considered unhealthy
+	HealthCheckTimeout time.Duration
+	// Logger receives replicas becoming unhealthy and recovering, nil means slog.Default()
+	Logger *slog.Logger
+}
+
+type replica struct {
+	opener  *ConnectionOpener
+	healthy atomic.Bool
+	// latency is how long the last successful health check took, in nanoseconds
+	latency atomic.Int64
+}
+
+// RoutingConnectionOpener sends writes to a primary and reads to its replicas. Replicas are pinged every health check
+// interval, and reads go to the primary whenever none of them are healthy, or when asked to with WithReadYourWrites.
+// Replicas lagging behind the primary are not detected, only those that can't be reached.
+type RoutingConnectionOpener struct {
+	primary            *ConnectionOpener
+	replicas           []*replica
+	selection          ReplicaSelection
+	healthCheckTimeout time.Duration
+	logger             *slog.Logger
+	next               atomic.Uint64
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewRoutingConnectionOpener(primary *ConnectionOpener, replicas []*ConnectionOpener) *RoutingConnectionOpener {
+	return NewRoutingConnectionOpenerWithOptions(primary, replicas, RoutingConnectionOpenerOptions{})
+}
+
+// NewRoutingConnectionOpenerWithOptions creates a RoutingConnectionOpener, which takes over the openers given to it and
+// closes them when closed. The replicas are checked once before it is returned, so reads are routed to those that are
+// healthy from the start.
+func NewRoutingConnectionOpenerWithOptions(primary *ConnectionOpener, replicas []*ConnectionOpener, options RoutingConnectionOpenerOptions) *RoutingConnectionOpener {
+	healthCheckInterval := options.HealthCheckInterval
+	if healthCheckInterval <= 0 {
+		healthCheckInterval = DefaultHealthCheckInterval
+	}
+	healthCheckTimeout := options.HealthCheckTimeout
+	if healthCheckTimeout <= 0 {
+		healthCheckTimeout = DefaultHealthCheckTimeout
+	}
+	logger := options.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	ret := &RoutingConnectionOpener{
+		primary:            primary,
+		replicas:           make([]*replica, len(replicas)),
+		selection:          options.Selection,
+		healthCheckTimeout: healthCheckTimeout,
+		logger:             logger,
+		stop:               make(chan struct{}),
+		done:               make(chan struct{}),
+	}
+	for i, opener := range replicas {
+		ret.replicas[i] = &replica{opener: opener}
+		ret.replicas[i].healthy.Store(true)
+	}
+	ret.CheckHealth(context.Background())
+	go ret.run(healthCheckInterval)
+	return ret
+}
+
+// OpenConnection hands back the primary's pool, for writes and anything else that has to see the latest data
+func (r *RoutingConnectionOpener) OpenConnection() (*sql.DB, error) {
+	return r.primary.OpenConnection()
+}
+
+// OpenReadConnection hands back the pool of a healthy replica, or the primary's pool if there are none or ctx came from
+// WithReadYourWrites
+func (r *RoutingConnectionOpener) OpenReadConnection(ctx context.Context) (*sql.DB, error) {
+	if readYourWrites(ctx) {
+		return r.primary.OpenConnection()
+	}
+	replica := r.pickReplica()
+	if replica == nil {
+		return r.primary.OpenConnection()
+	}
+	return replica.opener.OpenConnection()
+}
+
+// DataSourceName is the primary's connection string, see ConnectionOpener.DataSourceName
+func (r *RoutingConnectionOpener) DataSourceName() string {
+	return r.primary.DataSourceName()
+}
+
+// CurrentDataSourceName is the primary's current connection string, see ConnectionOpener.CurrentDataSourceName
+func (r *RoutingConnectionOpener) CurrentDataSourceName(ctx context.Context) (string, error) {
+	return r.primary.CurrentDataSourceName(ctx)
+}
+
+// CheckHealth pings every replica, reads being routed away from those that don't answer until they do again. It is
+// called every health check interval, so only needs calling to notice changes sooner.
+func (r *RoutingConnectionOpener) CheckHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i, replica := range r.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.checkReplica(ctx, i, replica)
+		}()
+	}
+	wg.Wait()
+}
+
+// Close stops health checks and closes the primary and every replica
+func (r *RoutingConnectionOpener) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+	errs := []error{r.primary.Close()}
+	for _, replica := range r.replicas {
+		errs = append(errs, replica.opener.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (r *RoutingConnectionOpener) pickReplica() *replica {
+	healthy := make([]*replica, 0, len(r.replicas))
+	for _, replica := range r.replicas {
+		if replica.healthy.Load() {
+			healthy = append(healthy, replica)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	if r.selection == LeastLatency {
+		ret := healthy[0]
+		for _, replica := range healthy[1:] {
+			if replica.latency.Load() < ret.latency.Load() {
+				ret = replica
+			}
+		}
+		return ret
+	}
+	return healthy[(r.next.Add(1)-1)%uint64(len(healthy))]
+}
+
+func (r *RoutingConnectionOpener) checkReplica(ctx context.Context, index int, replica *replica) {
+	ctx, cancel := context.WithTimeout(ctx, r.healthCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	err := ping(ctx, replica.opener)
+	if err != nil {
+		if replica.healthy.Swap(false) {
+			r.logger.WarnContext(ctx, "read replica unhealthy, routing its reads elsewhere", "replica", index, "error", err)
+		}
+		return
+	}
+	replica.latency.Store(int64(time.Since(start)))
+	if !replica.healthy.Swap(true) {
+		r.logger.InfoContext(ctx, "read replica healthy again", "replica", index)
+	}
+}
+
+func (r *RoutingConnectionOpener) run(healthCheckInterval time.Duration) {
+	defer close(r.done)
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.CheckHealth(context.Background())
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func ping(ctx context.Context, opener *ConnectionOpener) error {
+	conn, err := opener.OpenConnection()
+	if err != nil {
+		return err
+	}
+	return conn.PingContext(ctx)
+}
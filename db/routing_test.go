@@ -0,0 +1,198 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unreachableParams describes a database nothing is listening for, so that health checks against it fail
+var unreachableParams = ConnectionParams{Host: "127.0.0.1", Port: 1, User: "postgres"}
+
+// newTestRoutingConnectionOpener builds a routing opener in front of replicas that can't be reached, which have all been
+// found to be unhealthy by the time it is returned. Health checks are left to the test from then on.
+func newTestRoutingConnectionOpener(t *testing.T, replicas int, selection ReplicaSelection, logs *bytes.Buffer) *RoutingConnectionOpener {
+	replicaOpeners := make([]*ConnectionOpener, replicas)
+	for i := range replicaOpeners {
+		replicaOpeners[i] = NewConnectionOpener(unreachableParams)
+	}
+	ret := NewRoutingConnectionOpenerWithOptions(NewConnectionOpener(unreachableParams), replicaOpeners, RoutingConnectionOpenerOptions{
+		Selection:           selection,
+		HealthCheckInterval: time.Hour,
+		Logger:              slog.New(slog.NewTextHandler(logs, nil)),
+	})
+	t.Cleanup(func() {
+		assert.NoError(t, ret.Close())
+	})
+	return ret
+}
+
+func TestRoutingConnectionOpener_OpenReadConnection(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name      string
+		selection ReplicaSelection
+		healthy   []bool
+		latencies []time.Duration
+		ctx       context.Context
+		// expected lists which opener each read should go to in turn, -1 being the primary
+		expected []int
+	}{
+		{
+			name:      "round robin",
+			selection: RoundRobin,
+			healthy:   []bool{true, true, true},
+			ctx:       ctx,
+			expected:  []int{0, 1, 2, 0, 1},
+		},
+		{
+			name:      "round robin skips unhealthy replicas",
+			selection: RoundRobin,
+			healthy:   []bool{true, false, true},
+			ctx:       ctx,
+			expected:  []int{0, 2, 0, 2},
+		},
+		{
+			name:      "least latency",
+			selection: LeastLatency,
+			healthy:   []bool{true, true, true},
+			latencies: []time.Duration{20 * time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond},
+			ctx:       ctx,
+			expected:  []int{1, 1, 1},
+		},
+		{
+			name:      "least latency skips unhealthy replicas",
+			selection: LeastLatency,
+			healthy:   []bool{true, false, true},
+			latencies: []time.Duration{20 * time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond},
+			ctx:       ctx,
+			expected:  []int{2, 2},
+		},
+		{
+			name:      "no healthy replicas",
+			selection: RoundRobin,
+			healthy:   []bool{false, false},
+			ctx:       ctx,
+			expected:  []int{-1, -1},
+		},
+		{
+			name:      "no replicas",
+			selection: RoundRobin,
+			ctx:       ctx,
+			expected:  []int{-1, -1},
+		},
+		{
+			name:      "read your writes",
+			selection: RoundRobin,
+			healthy:   []bool{true, true},
+			ctx:       WithReadYourWrites(ctx),
+			expected:  []int{-1, -1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testInstance := newTestRoutingConnectionOpener(t, len(tc.healthy), tc.selection, &bytes.Buffer{})
+			for i, healthy := range tc.healthy {
+				testInstance.replicas[i].healthy.Store(healthy)
+			}
+			for i, latency := range tc.latencies {
+				testInstance.replicas[i].latency.Store(int64(latency))
+			}
+
+			for _, expected := range tc.expected {
+				var expectedConn *sql.DB
+				var err error
+				if expected < 0 {
+					expectedConn, err = testInstance.primary.OpenConnection()
+				} else {
+					expectedConn, err = testInstance.replicas[expected].opener.OpenConnection()
+				}
+				require.NoError(t, err)
+
+				conn, err := testInstance.OpenReadConnection(tc.ctx)
+				require.NoError(t, err)
+				assert.Same(t, expectedConn, conn)
+			}
+
+			// writes always go to the primary
+			expectedConn, err := testInstance.primary.OpenConnection()
+			require.NoError(t, err)
+			conn, err := testInstance.OpenConnection()
+			require.NoError(t, err)
+			assert.Same(t, expectedConn, conn)
+		})
+	}
+}
+
+func TestRoutingConnectionOpener_CheckHealth(t *testing.T) {
+	ctx := context.Background()
+	logs := &bytes.Buffer{}
+	testInstance := newTestRoutingConnectionOpener(t, 2, RoundRobin, logs)
+	primary, err := testInstance.primary.OpenConnection()
+	require.NoError(t, err)
+
+	// neither replica could be reached when first checked
+	for _, replica := range testInstance.replicas {
+		assert.False(t, replica.healthy.Load())
+	}
+	assert.Equal(t, 2, bytes.Count(logs.Bytes(), []byte("read replica unhealthy")))
+	conn, err := testInstance.OpenReadConnection(ctx)
+	require.NoError(t, err)
+	assert.Same(t, primary, conn)
+
+	// a replica thought to be healthy is routed away from as soon as it fails a check, which is only logged once
+	testInstance.replicas[1].healthy.Store(true)
+	replica, err := testInstance.replicas[1].opener.OpenConnection()
+	require.NoError(t, err)
+	conn, err = testInstance.OpenReadConnection(ctx)
+	require.NoError(t, err)
+	assert.Same(t, replica, conn)
+
+	testInstance.CheckHealth(ctx)
+	testInstance.CheckHealth(ctx)
+	assert.False(t, testInstance.replicas[1].healthy.Load())
+	assert.Equal(t, 3, bytes.Count(logs.Bytes(), []byte("read replica unhealthy")))
+	conn, err = testInstance.OpenReadConnection(ctx)
+	require.NoError(t, err)
+	assert.Same(t, primary, conn)
+}
+
+func TestRoutingConnectionOpener_CheckHealth_Postgres(t *testing.T) {
+	ctx := context.Background()
+	connectionCfg, err := ConnectionParamsFromEnv()
+	require.NoError(t, err)
+
+	logs := &bytes.Buffer{}
+	testInstance := NewRoutingConnectionOpenerWithOptions(NewConnectionOpener(connectionCfg), []*ConnectionOpener{
+		NewConnectionOpener(connectionCfg),
+		NewConnectionOpener(unreachableParams),
+	}, RoutingConnectionOpenerOptions{
+		HealthCheckInterval: time.Hour,
+		Logger:              slog.New(slog.NewTextHandler(logs, nil)),
+	})
+	defer testInstance.Close()
+
+	assert.True(t, testInstance.replicas[0].healthy.Load())
+	assert.Positive(t, testInstance.replicas[0].latency.Load())
+	assert.False(t, testInstance.replicas[1].healthy.Load())
+
+	// a replica that comes back is routed to again once it passes a check
+	testInstance.replicas[0].healthy.Store(false)
+	testInstance.CheckHealth(ctx)
+	assert.True(t, testInstance.replicas[0].healthy.Load())
+	assert.Contains(t, logs.String(), "read replica healthy again")
+	replica, err := testInstance.replicas[0].opener.OpenConnection()
+	require.NoError(t, err)
+	for range 3 {
+		conn, err := testInstance.OpenReadConnection(ctx)
+		require.NoError(t, err)
+		assert.Same(t, replica, conn)
+	}
+}
@@ -1 +1 @@
-drop table creatures;
\ No newline at end of file
+drop table creatures;
@@ -0,0 +1,2 @@
+drop trigger creatures_notify_change on creatures;
+drop function notify_creature_change();
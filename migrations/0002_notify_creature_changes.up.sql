@@ -0,0 +1,15 @@
+create function notify_creature_change() returns trigger as $$
+begin
+    if tg_op in ('UPDATE', 'DELETE') then
+        perform pg_notify('creature_changes', old.id::text);
+    end if;
+    if tg_op = 'INSERT' or (tg_op = 'UPDATE' and new.id <> old.id) then
+        perform pg_notify('creature_changes', new.id::text);
+    end if;
+    return null;
+end;
+$$ language plpgsql;
+
+create trigger creatures_notify_change
+    after insert or update or delete on creatures
+    for each row execute function notify_creature_change();
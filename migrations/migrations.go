@@ -0,0 +1,9 @@
+// Package migrations embeds the database migrations, so that they ship with whatever runs them. See db.Migrator.
+package migrations
+
+import "embed"
+
+// FS holds the migrations, named {version}_{description}.{up|down}.sql
+//
+//go:embed *.sql
+var FS embed.FS
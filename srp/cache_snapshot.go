@@ -0,0 +1,59 @@
+package srp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheSnapshotVersion is bumped whenever the snapshot format changes in a way older snapshots can't be read as
+const cacheSnapshotVersion = 1
+
+// cacheSnapshot is what CachingCreatureRepo saves to disk so that cached creatures survive a restart
+type cacheSnapshot struct {
+	Version int
+	SavedAt time.Time
+	Entries map[int64]CreatureCacheEntry
+}
+
+// writeCacheSnapshot saves a snapshot to path. The snapshot is written to a temporary file that then takes the place of
+// anything already at path, so a crash part way through can't leave a partially written snapshot behind.
+func writeCacheSnapshot(path string, snapshot cacheSnapshot) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating snapshot file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if err := json.NewEncoder(f).Encode(snapshot); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("replacing snapshot: %w", err)
+	}
+	return nil
+}
+
+// readCacheSnapshot loads the snapshot at path, errors satisfying errors.Is(err, fs.ErrNotExist) mean there isn't one
+func readCacheSnapshot(path string) (cacheSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return cacheSnapshot{}, err
+	}
+	defer f.Close()
+
+	var ret cacheSnapshot
+	if err := json.NewDecoder(f).Decode(&ret); err != nil {
+		return cacheSnapshot{}, fmt.Errorf("reading snapshot: %w", err)
+	}
+	if ret.Version != cacheSnapshotVersion {
+		return cacheSnapshot{}, fmt.Errorf("snapshot is version %d, expected version %d", ret.Version, cacheSnapshotVersion)
+	}
+	return ret, nil
+}
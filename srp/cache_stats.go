@@ -0,0 +1,55 @@
+package srp
+
+import "time"
+
+// CacheStats is a point in time view of the bookkeeping of a CachingCreatureRepo. Lookups are counted per id, so a
+// GetCreatures call may account for any number of hits and misses, while lookups by name count once each.
+type CacheStats struct {
+	// Size is the number of entries currently held, including any that have expired but not yet been swept. Size,
+	// Evictions and Expirations are reported by the cache itself, and are left at zero by caches that don't keep track.
+	Size int
+	// Evictions counts entries thrown away to make room for new ones
+	Evictions uint64
+	// Expirations counts expired entries removed by the janitor
+	Expirations uint64
+	// Hits counts lookups served from cache, including stale and refresh ahead hits
+	Hits uint64
+	// NegativeHits counts hits on entries for creatures that could not be found
+	NegativeHits uint64
+	// StaleHits counts lookups served from an expired entry within its stale window
+	StaleHits uint64
+	// RefreshAheadHits counts lookups served from an entry close enough to expiring for it to be refreshed ahead of time
+	RefreshAheadHits uint64
+	// Misses counts lookups that had to wait on the raw repo, including those sharing a load with another caller
+	Misses uint64
+	// Loads counts calls made to the raw repo to look creatures up, including background refreshes
+	Loads uint64
+	// LoadErrors counts loads that failed
+	LoadErrors uint64
+	// LoadTime is the total time spent on loads
+	LoadTime time.Duration
+	// RefreshErrors counts failed background refreshes
+	RefreshErrors uint64
+	// CacheErrors counts failed reads and writes of the cache, which are treated as misses and removals respectively
+	CacheErrors uint64
+	// PendingWrites is the number of creates queued under WriteBehind that have yet to be made
+	PendingWrites int
+	// WriteErrors counts creates queued under WriteBehind that failed once made
+	WriteErrors uint64
+}
+
+// HitRatio is the fraction of lookups served from cache, zero if there have been no lookups
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// AverageLoadLatency is the mean time taken by loads, zero if there have been none
+func (s CacheStats) AverageLoadLatency() time.Duration {
+	if s.Loads == 0 {
+		return 0
+	}
+	return s.LoadTime / time.Duration(s.Loads)
+}
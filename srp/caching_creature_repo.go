@@ -2,77 +2,1020 @@ package srp
 
 import (
 	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"math/rand/v2"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-type cachedLookupResult struct {
-	result    CreatureLookupResult
-	timestamp time.Time
+// inflightLoad is a GetCreature call to the raw repo that other callers asking for the same id can wait on rather than
+// issuing a call of their own
+type inflightLoad struct {
+	done   chan struct{}
+	result CreatureLookupResult
+	err    error
+	// stale is set if the entry is written while the load is running, in which case the (possibly outdated) result of
+	// the load is handed to its callers but not cached
+	stale bool
 }
 
-func (c cachedLookupResult) expired(cacheDuration time.Duration) bool {
-	return time.Now().Sub(c.timestamp) > cacheDuration
+// warmUpBatchSize caps the number of ids asked of the raw repo at once while warming up
+const warmUpBatchSize = DefaultMaxListPageSize
+
+// repoShard holds the bookkeeping for a subset of ids, so that work on ids in different shards doesn't contend
+type repoShard struct {
+	inflight map[int64]*inflightLoad
+	// changes counts changes to the entries of the shard's ids. While readers, the number of readGuards outstanding, is
+	// above zero changedAt records the count as of the last change to each id, and clearedAt the count as of the last
+	// InvalidateAll, so that guarded reads can tell which ids changed while they ran.
+	changes   uint64
+	readers   int
+	changedAt map[int64]uint64
+	clearedAt uint64
+	// mutex guards everything above. It is also held while writing entries for the shard's ids to the cache so that
+	// writes land in the order they were made, and must never be held while calling the raw repo.
+	mutex sync.Mutex
+}
+
+// readGuard covers a call to the raw repo made other than through an inflightLoad, such as a listing, where the ids that
+// will come back aren't known up front. Results for ids that changed while the call was running are left uncached, as
+// the call may have read from before the change.
+type readGuard struct {
+	started map[*repoShard]uint64
+}
+
+// changedLocked reports if an id changed since the guarded read started, callers must hold the lock of the id's shard
+func (g *readGuard) changedLocked(shard *repoShard, id int64) bool {
+	started := g.started[shard]
+	return shard.clearedAt > started || shard.changedAt[id] > started
+}
+
+// cacheBypassKey marks contexts created by WithCacheBypass
+type cacheBypassKey struct{}
+
+// WithCacheBypass returns a context that has CachingCreatureRepo lookups skip the cache and go straight to the raw repo,
+// with what comes back replacing whatever was cached. Raw repos pay it no mind, so code making use of it doesn't need to
+// know if caching is in the mix.
+func WithCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(cacheBypassKey{}).(bool)
+	return bypass
 }
 
 type RawCreatureRepo interface {
 	CreateCreature(ctx context.Context, name, description string) (Creature, error)
+	CreateCreatures(ctx context.Context, creatures []Creature) ([]Creature, error)
 	GetCreature(ctx context.Context, id int64) (CreatureLookupResult, error)
+	GetCreatures(ctx context.Context, ids []int64) (map[int64]CreatureLookupResult, error)
+	GetCreatureByName(ctx context.Context, name string) (CreatureLookupResult, error)
+	UpdateCreature(ctx context.Context, id int64, update CreatureUpdate) (CreatureUpdateResult, error)
+	DeleteCreature(ctx context.Context, id int64) (CreatureDeleteResult, error)
+	ListCreatures(ctx context.Context, options ListOptions) (CreaturePage, error)
+}
+
+type CachingCreatureRepoOptions struct {
+	CacheDuration time.Duration
+	// NegativeCacheDuration controls how long not-found results are cached for, nil means the same as CacheDuration and
+	// zero disables caching of not-found results altogether
+	NegativeCacheDuration *time.Duration
+	// Cache is where entries are kept. Nil means a MemoryCreatureCache, or a ShardedMemoryCreatureCache if Shards is more
+	// than one, built from MaxEntries, EvictionPolicy and JanitorInterval, which are ignored otherwise. Caches passed in
+	// remain owned by the caller.
+	Cache CreatureCache
+	// Shards splits the repo's own bookkeeping, along with the default cache, into this many independently locked
+	// shards to cut down on lock contention under concurrent load. Zero or one means a single shard.
+	Shards int
+	// MaxEntries bounds the number of cached entries, with EvictionPolicy deciding what goes when the cache is full.
+	// Zero leaves the cache unbounded.
+	MaxEntries     int
+	EvictionPolicy EvictionPolicy
+	// JanitorInterval controls how often a background goroutine sweeps expired entries out of the cache, zero disables
+	// sweeping. Repos with a janitor need to be closed once they are no longer needed.
+	JanitorInterval time.Duration
+	// StaleWindow allows entries to be served for a while after they expire, with a background refresh being kicked off
+	// when that happens. Zero disables serving stale entries.
+	StaleWindow time.Duration
+	// TTLJitter shortens the lifetime of each entry by a random fraction of up to this much of its cache duration, so
+	// entries cached together don't all expire together. 0.1 would have entries live for between 90% and 100% of the
+	// cache duration. Zero disables jitter, and values outside of 0 to 1 are clamped to that range.
+	TTLJitter float64
+	// RefreshAheadThreshold kicks off a background refresh when an entry is hit within this fraction of the end of its
+	// lifetime, so that popular entries are replaced before they expire. 0.2 would refresh entries hit in the last 20% of
+	// their lifetime. Zero disables refreshing ahead, while one refreshes entries on every hit. Values outside of 0 to 1
+	// are clamped to that range.
+	RefreshAheadThreshold float64
+	// Clock is used for all expiry decisions, as well as timing calls to the raw repo. Nil means the system clock. Clocks
+	// implementing TickerClock also decide when the janitor of the default cache sweeps, and when creates queued under
+	// WriteBehind are flushed.
+	Clock Clock
+	// Logger receives reports of problems that can't be surfaced to callers, such as failed background refreshes. Nil
+	// means slog.Default().
+	Logger *slog.Logger
+	// SnapshotPath, if set, has the repo save what it has cached to this file when closed, and restore it when created,
+	// so that cached creatures survive a restart. Restored entries keep the timestamps they were cached with, so
+	// anything that expired in the meantime is left behind. Only caches able to list their entries, such as the in-memory
+	// ones, can be saved.
+	SnapshotPath string
+	// WritePolicy decides how writes are handled, the zero value being WriteThrough
+	WritePolicy WritePolicy
+	// WriteBehindQueueSize bounds the number of creates waiting to be made under WriteBehind, with further creates
+	// waiting for room, while WriteBehindBatchSize and WriteBehindFlushInterval control how many creates are made at a
+	// time and how long they may wait. Zero means DefaultWriteBehindQueueSize, DefaultWriteBehindBatchSize and
+	// DefaultWriteBehindFlushInterval respectively. Repos writing behind need to be closed once they are no longer
+	// needed, which makes any creates still waiting.
+	WriteBehindQueueSize     int
+	WriteBehindBatchSize     int
+	WriteBehindFlushInterval time.Duration
 }
 
 type CachingCreatureRepo struct {
-	rawRepo       RawCreatureRepo
-	cacheDuration time.Duration
+	rawRepo               RawCreatureRepo
+	cacheDuration         time.Duration
+	negativeCacheDuration time.Duration
+	staleWindow           time.Duration
+	ttlJitter             float64
+	refreshAheadThreshold float64
+	clock                 Clock
+	logger                *slog.Logger
+	snapshotPath          string
+	writePolicy           WritePolicy
+	// writeBehind is only set under WriteBehind
+	writeBehind *writeBehindQueue
+
+	cache CreatureCache
+	// ownsCache is set when the repo built its own cache, in which case closing the repo closes the cache
+	ownsCache bool
+	// nameIndex maps names of cached creatures to their ids, with indexedNames going the other way so the index can be
+	// kept in step as entries change. The cache may drop entries without the index knowing, so the cache always has
+	// the final say, and index entries for ids found missing from the cache are pruned as they turn up.
+	nameIndex    map[string]int64
+	indexedNames map[int64]string
+	// indexMutex guards nameIndex and indexedNames. It may be taken while holding a shard's mutex, but not the other way
+	// around.
+	indexMutex sync.Mutex
+	shards     []*repoShard
+
+	hits             atomic.Uint64
+	negativeHits     atomic.Uint64
+	staleHits        atomic.Uint64
+	refreshAheadHits atomic.Uint64
+	misses           atomic.Uint64
+	loads            atomic.Uint64
+	loadErrors       atomic.Uint64
+	loadTime         atomic.Int64
+	refreshErrors    atomic.Uint64
+	cacheErrors      atomic.Uint64
+	writeErrors      atomic.Uint64
 
-	cache      map[int64]cachedLookupResult
-	cacheMutex sync.RWMutex
+	closeOnce sync.Once
 }
 
 func NewCachingCreatureRepo(rawRepo RawCreatureRepo, cacheDuration time.Duration) *CachingCreatureRepo {
-	return &CachingCreatureRepo{
-		cache:         make(map[int64]cachedLookupResult),
-		rawRepo:       rawRepo,
-		cacheDuration: cacheDuration,
+	return NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration: cacheDuration,
+	})
+}
+
+func NewCachingCreatureRepoWithOptions(rawRepo RawCreatureRepo, options CachingCreatureRepoOptions) *CachingCreatureRepo {
+	negativeCacheDuration := options.CacheDuration
+	if options.NegativeCacheDuration != nil {
+		negativeCacheDuration = *options.NegativeCacheDuration
+	}
+	clock := options.Clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	logger := options.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	shards := max(options.Shards, 1)
+	cache := options.Cache
+	ownsCache := false
+	if cache == nil {
+		cacheOptions := MemoryCreatureCacheOptions{
+			MaxEntries:      options.MaxEntries,
+			EvictionPolicy:  options.EvictionPolicy,
+			JanitorInterval: options.JanitorInterval,
+			Clock:           clock,
+		}
+		if shards > 1 {
+			cache = NewShardedMemoryCreatureCache(shards, cacheOptions)
+		} else {
+			cache = NewMemoryCreatureCache(cacheOptions)
+		}
+		ownsCache = true
+	}
+	ret := &CachingCreatureRepo{
+		cache:                 cache,
+		ownsCache:             ownsCache,
+		nameIndex:             make(map[string]int64),
+		indexedNames:          make(map[int64]string),
+		shards:                make([]*repoShard, shards),
+		rawRepo:               rawRepo,
+		cacheDuration:         options.CacheDuration,
+		negativeCacheDuration: negativeCacheDuration,
+		staleWindow:           options.StaleWindow,
+		ttlJitter:             clampFraction(options.TTLJitter),
+		refreshAheadThreshold: clampFraction(options.RefreshAheadThreshold),
+		clock:                 clock,
+		logger:                logger,
+		snapshotPath:          options.SnapshotPath,
+		writePolicy:           options.WritePolicy,
+	}
+	for i := range ret.shards {
+		ret.shards[i] = &repoShard{
+			inflight:  make(map[int64]*inflightLoad),
+			changedAt: make(map[int64]uint64),
+		}
 	}
+	if ret.snapshotPath != "" {
+		ret.restoreSnapshot(context.Background())
+	}
+	if ret.writePolicy == WriteBehind {
+		queueSize := options.WriteBehindQueueSize
+		if queueSize <= 0 {
+			queueSize = DefaultWriteBehindQueueSize
+		}
+		batchSize := options.WriteBehindBatchSize
+		if batchSize <= 0 {
+			batchSize = DefaultWriteBehindBatchSize
+		}
+		flushInterval := options.WriteBehindFlushInterval
+		if flushInterval <= 0 {
+			flushInterval = DefaultWriteBehindFlushInterval
+		}
+		ret.writeBehind = newWriteBehindQueue(queueSize, batchSize, flushInterval, clock, ret.writeBehindBatch)
+	}
+	return ret
+}
+
+// Close makes any creates waiting to be written behind, saves a snapshot if configured to, and releases the cache if the
+// repo created it, stopping its janitor if there is one. The repo remains usable afterward, writing through from then on
+// if it was writing behind.
+func (c *CachingCreatureRepo) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		if c.writeBehind != nil {
+			c.writeBehind.close()
+		}
+		if c.snapshotPath != "" {
+			err = c.saveSnapshot()
+		}
+		if closer, closable := c.cache.(io.Closer); c.ownsCache && closable {
+			err = errors.Join(err, closer.Close())
+		}
+	})
+	return err
 }
 
+func (c *CachingCreatureRepo) Stats() CacheStats {
+	var ret CacheStats
+	if reporter, reports := c.cache.(cacheStatsReporter); reports {
+		ret = reporter.Stats()
+	}
+	ret.Hits = c.hits.Load()
+	ret.NegativeHits = c.negativeHits.Load()
+	ret.StaleHits = c.staleHits.Load()
+	ret.RefreshAheadHits = c.refreshAheadHits.Load()
+	ret.Misses = c.misses.Load()
+	ret.Loads = c.loads.Load()
+	ret.LoadErrors = c.loadErrors.Load()
+	ret.LoadTime = time.Duration(c.loadTime.Load())
+	ret.RefreshErrors = c.refreshErrors.Load()
+	ret.CacheErrors = c.cacheErrors.Load()
+	ret.WriteErrors = c.writeErrors.Load()
+	if c.writeBehind != nil {
+		ret.PendingWrites = c.writeBehind.len()
+	}
+	return ret
+}
+
+// Flush waits for creates queued under WriteBehind up until now to be made, or for ctx to be done. It does nothing under
+// other policies.
+func (c *CachingCreatureRepo) Flush(ctx context.Context) error {
+	if c.writeBehind == nil {
+		return nil
+	}
+	return c.writeBehind.flush(ctx)
+}
+
+// CreateCreature is handled according to the repo's WritePolicy, see WriteBehind in particular for what to expect when
+// writing behind. Creatures coming back from the raw repo without an ID, as they do from another CachingCreatureRepo
+// writing behind, can't be keyed by it so aren't cached.
 func (c *CachingCreatureRepo) CreateCreature(ctx context.Context, name, description string) (Creature, error) {
+	if c.writeBehind != nil {
+		queued, err := c.writeBehind.enqueue(ctx, pendingCreate{
+			ctx:         context.WithoutCancel(ctx),
+			name:        name,
+			description: description,
+		})
+		if err != nil {
+			return Creature{}, err
+		}
+		if queued {
+			return Creature{Name: name, Description: description}, nil
+		}
+		// the repo has been closed so there is nothing left to write behind, but it can still write through
+	}
 	res, err := c.rawRepo.CreateCreature(ctx, name, description)
-	if err != nil {
+	if err != nil || res.ID == 0 {
 		return res, err
 	}
-	c.cacheMutex.Lock()
-	c.cache[res.ID] = cachedLookupResult{
-		result: CreatureLookupResult{
+	shard := c.shard(res.ID)
+	shard.mutex.Lock()
+	c.writtenLocked(ctx, shard, res.ID, CreatureLookupResult{
+		ResultFound: true,
+		Creature:    res,
+	})
+	shard.mutex.Unlock()
+	return res, err
+}
+
+// CreateCreatures makes a batch of creates in a single round trip to the raw repo, caching the creatures made according
+// to the repo's WritePolicy. Under WriteBehind each create is queued just as it would be by CreateCreature.
+func (c *CachingCreatureRepo) CreateCreatures(ctx context.Context, creatures []Creature) ([]Creature, error) {
+	if c.writeBehind != nil {
+		ret := make([]Creature, 0, len(creatures))
+		for _, creature := range creatures {
+			res, err := c.CreateCreature(ctx, creature.Name, creature.Description)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, res)
+		}
+		return ret, nil
+	}
+	res, err := c.rawRepo.CreateCreatures(ctx, creatures)
+	if err != nil {
+		return nil, err
+	}
+	for _, creature := range res {
+		if creature.ID == 0 {
+			continue
+		}
+		shard := c.shard(creature.ID)
+		shard.mutex.Lock()
+		c.writtenLocked(ctx, shard, creature.ID, CreatureLookupResult{
 			ResultFound: true,
-			Creature:    res,
-		},
-		timestamp: time.Now(),
+			Creature:    creature,
+		})
+		shard.mutex.Unlock()
 	}
-	c.cacheMutex.Unlock()
-	return res, err
+	return res, nil
 }
 
+// GetCreature serves lookups from cache when possible. Concurrent misses for the same id share a single call to the raw
+// repo, while misses for different ids proceed independently. The shared call carries on without regard to any one
+// caller's cancellation or deadline, with each caller giving up waiting on it as their own context is done. Entries within
+// their stale window, or due to be refreshed ahead of expiry, are returned immediately with a refresh happening in the
+// background. Contexts from WithCacheBypass skip the cache and always go to the raw repo.
 func (c *CachingCreatureRepo) GetCreature(ctx context.Context, id int64) (CreatureLookupResult, error) {
-	c.cacheMutex.RLock()
-	if result, cached := c.cache[id]; cached && !result.expired(c.cacheDuration) {
-		c.cacheMutex.RUnlock()
-		return result.result, nil
-	}
-	c.cacheMutex.RUnlock()
-	c.cacheMutex.Lock()
-	defer c.cacheMutex.Unlock()
+	if cacheBypassed(ctx) {
+		return c.reload(ctx, id)
+	}
+	if entry, cached := c.lookup(ctx, id); cached && !c.expired(entry) {
+		c.recordHit(entry.Result)
+		c.refreshAheadIfDue(ctx, id, entry)
+		return entry.Result, nil
+	}
+
+	shard := c.shard(id)
+	shard.mutex.Lock()
 	// let's make sure another concurrent request didn't already do the query, and if so lets return its result
-	if result, cached := c.cache[id]; cached && !result.expired(c.cacheDuration) {
-		return result.result, nil
+	entry, cached := c.lookup(ctx, id)
+	if cached && !c.expired(entry) {
+		shard.mutex.Unlock()
+		c.recordHit(entry.Result)
+		c.refreshAheadIfDue(ctx, id, entry)
+		return entry.Result, nil
 	}
-	result, err := c.rawRepo.GetCreature(ctx, id)
+	if !cached {
+		c.unindex(id)
+	}
+	load, loading := shard.inflight[id]
+	if !loading {
+		load = &inflightLoad{
+			done: make(chan struct{}),
+		}
+		shard.inflight[id] = load
+	}
+	shard.mutex.Unlock()
+
+	if cached && c.stale(entry) {
+		c.recordHit(entry.Result)
+		c.staleHits.Add(1)
+		if !loading {
+			// the refresh is on behalf of the cache rather than this caller, so it shouldn't be cut short if they go away
+			go c.refresh(context.WithoutCancel(ctx), shard, id, load)
+		}
+		return entry.Result, nil
+	}
+	c.misses.Add(1)
+	if !loading {
+		go c.load(context.WithoutCancel(ctx), shard, id, load)
+	}
+	return waitForLoad(ctx, load)
+}
+
+// GetCreatures serves what it can from cache and fetches everything else from the raw repo in a single call
+func (c *CachingCreatureRepo) GetCreatures(ctx context.Context, ids []int64) (map[int64]CreatureLookupResult, error) {
+	ret := make(map[int64]CreatureLookupResult, len(ids))
+	var missing []int64
+	requested := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if requested[id] {
+			continue
+		}
+		requested[id] = true
+		if cacheBypassed(ctx) {
+			missing = append(missing, id)
+		} else if entry, cached := c.lookup(ctx, id); cached && !c.expired(entry) {
+			c.recordHit(entry.Result)
+			ret[id] = entry.Result
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return ret, nil
+	}
+
+	c.misses.Add(uint64(len(missing)))
+	fetched, err := c.loadMany(ctx, missing)
 	if err != nil {
+		return nil, err
+	}
+	for id, result := range fetched {
+		ret[id] = result
+	}
+	return ret, nil
+}
+
+// GetCreatureByName is served from cache when the name index points at a live entry for the named creature. Creatures
+// that could not be found are not cached, as there is no id to key them by.
+func (c *CachingCreatureRepo) GetCreatureByName(ctx context.Context, name string) (CreatureLookupResult, error) {
+	c.indexMutex.Lock()
+	id, indexed := c.nameIndex[name]
+	c.indexMutex.Unlock()
+	if indexed && !cacheBypassed(ctx) {
+		if entry, cached := c.lookup(ctx, id); cached && !c.expired(entry) && entry.Result.ResultFound && entry.Result.Creature.Name == name {
+			c.recordHit(entry.Result)
+			return entry.Result, nil
+		}
+	}
+
+	c.misses.Add(1)
+	guard := c.startRead()
+	defer c.finishRead(guard)
+	start := c.clock.Now()
+	result, err := c.rawRepo.GetCreatureByName(ctx, name)
+	c.recordLoad(start, err)
+	if err != nil {
+		return result, err
+	}
+	if result.ResultFound {
+		c.storeRead(ctx, guard, result.Creature.ID, result, c.clock.Now())
 		return result, err
 	}
-	c.cache[id] = cachedLookupResult{
-		result:    result,
-		timestamp: time.Now(),
+	c.indexMutex.Lock()
+	id, indexed = c.nameIndex[name]
+	c.indexMutex.Unlock()
+	if indexed {
+		// whatever the index pointed at no longer goes by this name
+		shard := c.shard(id)
+		shard.mutex.Lock()
+		c.forgetLocked(ctx, shard, id)
+		shard.mutex.Unlock()
 	}
 	return result, err
 }
+
+func (c *CachingCreatureRepo) UpdateCreature(ctx context.Context, id int64, update CreatureUpdate) (CreatureUpdateResult, error) {
+	res, err := c.rawRepo.UpdateCreature(ctx, id, update)
+	shard := c.shard(id)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	if err != nil {
+		// we have no idea what state the record is in, so the safest thing to do is forget about it
+		c.forgetLocked(ctx, shard, id)
+		return res, err
+	}
+	c.writtenLocked(ctx, shard, id, CreatureLookupResult{
+		ResultFound: res.ResultFound,
+		Creature:    res.Creature,
+	})
+	return res, err
+}
+
+func (c *CachingCreatureRepo) DeleteCreature(ctx context.Context, id int64) (CreatureDeleteResult, error) {
+	res, err := c.rawRepo.DeleteCreature(ctx, id)
+	shard := c.shard(id)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	if err != nil {
+		c.forgetLocked(ctx, shard, id)
+		return res, err
+	}
+	// regardless of whether or not the record existed it is now gone
+	c.writtenLocked(ctx, shard, id, CreatureLookupResult{
+		ResultFound: false,
+	})
+	return res, err
+}
+
+// ListCreatures is never served from cache, however the creatures that come back are used to refresh their entries
+func (c *CachingCreatureRepo) ListCreatures(ctx context.Context, options ListOptions) (CreaturePage, error) {
+	guard := c.startRead()
+	defer c.finishRead(guard)
+	res, err := c.rawRepo.ListCreatures(ctx, options)
+	if err != nil {
+		return res, err
+	}
+	now := c.clock.Now()
+	for _, creature := range res.Creatures {
+		c.storeRead(ctx, guard, creature.ID, CreatureLookupResult{
+			ResultFound: true,
+			Creature:    creature,
+		}, now)
+	}
+	return res, err
+}
+
+// WarmUp loads creatures that aren't already cached, in batches, so that they can be served from cache from the get-go.
+// Warming up doesn't count towards the repo's hits and misses.
+func (c *CachingCreatureRepo) WarmUp(ctx context.Context, ids []int64) error {
+	var missing []int64
+	requested := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if requested[id] {
+			continue
+		}
+		requested[id] = true
+		if entry, cached := c.lookup(ctx, id); !cached || c.expired(entry) {
+			missing = append(missing, id)
+		}
+	}
+	for len(missing) > 0 {
+		batch := missing[:min(len(missing), warmUpBatchSize)]
+		missing = missing[len(batch):]
+		if _, err := c.loadMany(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WarmUpAll caches every creature there is by paging through ListCreatures, so should only be used when all creatures
+// fit in the cache
+func (c *CachingCreatureRepo) WarmUpAll(ctx context.Context) error {
+	options := ListOptions{
+		PageSize: warmUpBatchSize,
+		OrderBy:  OrderByID,
+	}
+	for {
+		page, err := c.ListCreatures(ctx, options)
+		if err != nil {
+			return err
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		options.Cursor = page.NextCursor
+	}
+}
+
+// Invalidate drops the cached entry for an id, such as following a change made behind the repo's back. Any lookup or
+// listing in flight that comes back with the id is prevented from putting back what it read from before the change.
+// Failures to remove the entry from the cache are logged and counted in CacheStats.CacheErrors.
+func (c *CachingCreatureRepo) Invalidate(ctx context.Context, id int64) {
+	shard := c.shard(id)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	c.forgetLocked(ctx, shard, id)
+}
+
+// InvalidateAll drops every cached entry. Note that with a shared cache this affects every repo using it.
+func (c *CachingCreatureRepo) InvalidateAll(ctx context.Context) {
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		defer shard.mutex.Unlock()
+		for _, load := range shard.inflight {
+			load.stale = true
+		}
+		shard.changes++
+		shard.clearedAt = shard.changes
+	}
+	ctx = context.WithoutCancel(ctx)
+	if err := c.cache.Clear(ctx); err != nil {
+		c.logger.ErrorContext(ctx, "error clearing cached creatures", "error", err)
+		c.cacheErrors.Add(1)
+	}
+	c.indexMutex.Lock()
+	c.nameIndex = make(map[string]int64)
+	c.indexedNames = make(map[int64]string)
+	c.indexMutex.Unlock()
+}
+
+// Peek returns the cached entry for an id without ever going to the raw repo. Entries that have expired but are still
+// within their stale window are returned too, their ExpiresAt tells them apart.
+func (c *CachingCreatureRepo) Peek(ctx context.Context, id int64) (CreatureCacheEntry, bool) {
+	return c.lookup(ctx, id)
+}
+
+// writtenLocked brings the cache in step with a successful write, callers must hold the lock of the id's shard
+func (c *CachingCreatureRepo) writtenLocked(ctx context.Context, shard *repoShard, id int64, result CreatureLookupResult) {
+	if c.writePolicy == WriteAround {
+		c.forgetLocked(ctx, shard, id)
+		return
+	}
+	c.storeLocked(ctx, shard, id, result, c.clock.Now())
+}
+
+// writeBehindBatch makes creates queued under WriteBehind in a single round trip to the raw repo, made with the context
+// of the first create in the batch. Should that fail, as it will if any one name is already in use, the creates are
+// made again one at a time, in the order they were queued, so that the rest of the batch isn't lost along with it.
+// Failures can't be handed back to anyone, so get reported instead.
+func (c *CachingCreatureRepo) writeBehindBatch(batch []pendingCreate) {
+	ctx := batch[0].ctx
+	creatures := make([]Creature, 0, len(batch))
+	for _, create := range batch {
+		creatures = append(creatures, Creature{Name: create.name, Description: create.description})
+	}
+	created, err := c.rawRepo.CreateCreatures(ctx, creatures)
+	if err != nil {
+		if len(batch) > 1 {
+			c.logger.WarnContext(ctx, "error writing batch of queued creatures, writing them one at a time instead", "creatures", len(batch), "error", err)
+			for _, create := range batch {
+				c.writeBehindOne(create)
+			}
+			return
+		}
+		c.logger.ErrorContext(ctx, "error writing queued creature", "name", batch[0].name, "error", err)
+		c.writeErrors.Add(1)
+		return
+	}
+	for _, res := range created {
+		c.storeCreated(ctx, res)
+	}
+}
+
+// writeBehindOne makes a single create queued under WriteBehind
+func (c *CachingCreatureRepo) writeBehindOne(create pendingCreate) {
+	res, err := c.rawRepo.CreateCreature(create.ctx, create.name, create.description)
+	if err != nil {
+		c.logger.ErrorContext(create.ctx, "error writing queued creature", "name", create.name, "error", err)
+		c.writeErrors.Add(1)
+		return
+	}
+	c.storeCreated(create.ctx, res)
+}
+
+// storeCreated caches a creature made in the background, unless the raw repo deferred it too and so has yet to hand out
+// its id
+func (c *CachingCreatureRepo) storeCreated(ctx context.Context, res Creature) {
+	if res.ID == 0 {
+		return
+	}
+	shard := c.shard(res.ID)
+	shard.mutex.Lock()
+	c.storeLocked(ctx, shard, res.ID, CreatureLookupResult{
+		ResultFound: true,
+		Creature:    res,
+	}, c.clock.Now())
+	shard.mutex.Unlock()
+}
+
+// refreshAheadIfDue starts a background refresh of an entry that was just hit if it is close enough to expiring, unless
+// the entry is already being loaded
+func (c *CachingCreatureRepo) refreshAheadIfDue(ctx context.Context, id int64, hit CreatureCacheEntry) {
+	if c.refreshAheadThreshold <= 0 {
+		return
+	}
+	lifetime := hit.ExpiresAt.Sub(hit.CachedAt)
+	if !c.clock.Now().After(hit.ExpiresAt.Add(-time.Duration(float64(lifetime) * c.refreshAheadThreshold))) {
+		return
+	}
+	c.refreshAheadHits.Add(1)
+
+	shard := c.shard(id)
+	shard.mutex.Lock()
+	if _, loading := shard.inflight[id]; loading {
+		shard.mutex.Unlock()
+		return
+	}
+	load := &inflightLoad{
+		done: make(chan struct{}),
+	}
+	shard.inflight[id] = load
+	shard.mutex.Unlock()
+
+	go c.refresh(context.WithoutCancel(ctx), shard, id, load)
+}
+
+// refresh is a load nobody is waiting on, so failures get reported rather than returned. The stale entry is left in place
+// on failure, so callers continue to be served from it until its stale window closes.
+func (c *CachingCreatureRepo) refresh(ctx context.Context, shard *repoShard, id int64, load *inflightLoad) {
+	c.load(ctx, shard, id, load)
+	if load.err != nil {
+		c.logger.ErrorContext(ctx, "error refreshing cached creature", "id", id, "error", load.err)
+		c.refreshErrors.Add(1)
+	}
+}
+
+// reload goes to the raw repo for an id regardless of what is cached. The load takes the place of any already in flight,
+// which may have read from before something the caller is expecting to see, and callers arriving while it runs share it.
+func (c *CachingCreatureRepo) reload(ctx context.Context, id int64) (CreatureLookupResult, error) {
+	shard := c.shard(id)
+	shard.mutex.Lock()
+	markStaleLocked(shard, id)
+	load := &inflightLoad{
+		done: make(chan struct{}),
+	}
+	shard.inflight[id] = load
+	shard.mutex.Unlock()
+
+	c.misses.Add(1)
+	go c.load(context.WithoutCancel(ctx), shard, id, load)
+	return waitForLoad(ctx, load)
+}
+
+// loadMany fetches creatures from the raw repo in a single call, caching what comes back
+func (c *CachingCreatureRepo) loadMany(ctx context.Context, ids []int64) (map[int64]CreatureLookupResult, error) {
+	guard := c.startRead()
+	defer c.finishRead(guard)
+	start := c.clock.Now()
+	fetched, err := c.rawRepo.GetCreatures(ctx, ids)
+	c.recordLoad(start, err)
+	if err != nil {
+		return nil, err
+	}
+	now := c.clock.Now()
+	for id, result := range fetched {
+		c.storeRead(ctx, guard, id, result, now)
+	}
+	return fetched, nil
+}
+
+// startRead sets up a readGuard for a call about to be made to the raw repo, which must be finished with finishRead
+func (c *CachingCreatureRepo) startRead() *readGuard {
+	ret := &readGuard{
+		started: make(map[*repoShard]uint64, len(c.shards)),
+	}
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		shard.readers++
+		ret.started[shard] = shard.changes
+		shard.mutex.Unlock()
+	}
+	return ret
+}
+
+func (c *CachingCreatureRepo) finishRead(guard *readGuard) {
+	for shard := range guard.started {
+		shard.mutex.Lock()
+		shard.readers--
+		if shard.readers == 0 {
+			clear(shard.changedAt)
+		}
+		shard.mutex.Unlock()
+	}
+}
+
+// storeRead caches a result that came back from a guarded read, unless the id changed while the read was running
+func (c *CachingCreatureRepo) storeRead(ctx context.Context, guard *readGuard, id int64, result CreatureLookupResult, timestamp time.Time) {
+	shard := c.shard(id)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	if !guard.changedLocked(shard, id) {
+		c.storeLocked(ctx, shard, id, result, timestamp)
+	}
+}
+
+func (c *CachingCreatureRepo) load(ctx context.Context, shard *repoShard, id int64, load *inflightLoad) {
+	start := c.clock.Now()
+	result, err := c.rawRepo.GetCreature(ctx, id)
+	c.recordLoad(start, err)
+	shard.mutex.Lock()
+	if shard.inflight[id] == load {
+		// a reload may have taken our place
+		delete(shard.inflight, id)
+	}
+	if err == nil && !load.stale {
+		c.storeLocked(ctx, shard, id, result, c.clock.Now())
+	}
+	shard.mutex.Unlock()
+	load.result = result
+	load.err = err
+	close(load.done)
+}
+
+// waitForLoad waits on a load for as long as ctx allows
+func waitForLoad(ctx context.Context, load *inflightLoad) (CreatureLookupResult, error) {
+	select {
+	case <-load.done:
+		return load.result, load.err
+	case <-ctx.Done():
+		return CreatureLookupResult{}, ctx.Err()
+	}
+}
+
+func (c *CachingCreatureRepo) recordHit(result CreatureLookupResult) {
+	c.hits.Add(1)
+	if !result.ResultFound {
+		c.negativeHits.Add(1)
+	}
+}
+
+// recordLoad accounts for a lookup made against the raw repo that started at start
+func (c *CachingCreatureRepo) recordLoad(start time.Time, err error) {
+	c.loads.Add(1)
+	c.loadTime.Add(int64(c.clock.Now().Sub(start)))
+	if err != nil {
+		c.loadErrors.Add(1)
+	}
+}
+
+// lookup reads an entry from the cache. A cache that can't be read from is treated as not having the entry, so that
+// callers fall back to the raw repo.
+func (c *CachingCreatureRepo) lookup(ctx context.Context, id int64) (CreatureCacheEntry, bool) {
+	entry, cached, err := c.cache.Get(ctx, id)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "error reading cached creature", "id", id, "error", err)
+		c.cacheErrors.Add(1)
+		return CreatureCacheEntry{}, false
+	}
+	return entry, cached
+}
+
+func (c *CachingCreatureRepo) expired(entry CreatureCacheEntry) bool {
+	return c.clock.Now().After(entry.ExpiresAt)
+}
+
+// stale reports if an expired entry may still be served while it gets refreshed
+func (c *CachingCreatureRepo) stale(entry CreatureCacheEntry) bool {
+	return c.staleWindow > 0 && !c.clock.Now().After(entry.ExpiresAt.Add(c.staleWindow))
+}
+
+// lifetime works out how long a result should be considered fresh for, applying jitter if configured
+func (c *CachingCreatureRepo) lifetime(result CreatureLookupResult) time.Duration {
+	ret := c.negativeCacheDuration
+	if result.ResultFound {
+		ret = c.cacheDuration
+	}
+	if c.ttlJitter > 0 {
+		ret -= time.Duration(float64(ret) * rand.Float64() * c.ttlJitter)
+	}
+	return ret
+}
+
+// storeLocked caches a result and keeps the name index in step with it, callers must hold the lock of the id's shard.
+// Cache writes are made without regard to cancellation of ctx, as giving up part way through could leave an outdated
+// entry behind.
+func (c *CachingCreatureRepo) storeLocked(ctx context.Context, shard *repoShard, id int64, result CreatureLookupResult, timestamp time.Time) {
+	markStaleLocked(shard, id)
+	if !result.ResultFound && c.negativeCacheDuration <= 0 {
+		// not found results aren't to be cached, but whatever was there before is no longer accurate
+		c.removeLocked(ctx, id)
+		return
+	}
+	lifetime := c.lifetime(result)
+	c.putLocked(ctx, id, CreatureCacheEntry{
+		Result:    result,
+		CachedAt:  timestamp,
+		ExpiresAt: timestamp.Add(lifetime),
+	}, lifetime+c.staleWindow)
+}
+
+// putLocked writes an entry to the cache and indexes it, callers must hold the lock of the id's shard
+func (c *CachingCreatureRepo) putLocked(ctx context.Context, id int64, entry CreatureCacheEntry, ttl time.Duration) {
+	ctx = context.WithoutCancel(ctx)
+	if err := c.cache.Set(ctx, id, entry, ttl); err != nil {
+		c.logger.ErrorContext(ctx, "error caching creature", "id", id, "error", err)
+		c.cacheErrors.Add(1)
+		// whatever is cached may well be outdated now, so make one last effort to get rid of it
+		c.removeLocked(ctx, id)
+		return
+	}
+	c.indexMutex.Lock()
+	c.unindexLocked(id)
+	if entry.Result.ResultFound {
+		c.nameIndex[entry.Result.Creature.Name] = id
+		c.indexedNames[id] = entry.Result.Creature.Name
+	}
+	c.indexMutex.Unlock()
+}
+
+// forgetLocked drops a cache entry, making sure any load in flight for it doesn't put it back. Callers must hold the lock
+// of the id's shard.
+func (c *CachingCreatureRepo) forgetLocked(ctx context.Context, shard *repoShard, id int64) {
+	markStaleLocked(shard, id)
+	c.removeLocked(ctx, id)
+}
+
+// removeLocked drops a cache entry along with its name index entry, callers must hold the lock of the id's shard
+func (c *CachingCreatureRepo) removeLocked(ctx context.Context, id int64) {
+	c.unindex(id)
+	ctx = context.WithoutCancel(ctx)
+	if err := c.cache.Delete(ctx, id); err != nil {
+		c.logger.ErrorContext(ctx, "error removing cached creature", "id", id, "error", err)
+		c.cacheErrors.Add(1)
+	}
+}
+
+func (c *CachingCreatureRepo) unindex(id int64) {
+	c.indexMutex.Lock()
+	c.unindexLocked(id)
+	c.indexMutex.Unlock()
+}
+
+// unindexLocked drops the name index entry for an id, callers must hold indexMutex
+func (c *CachingCreatureRepo) unindexLocked(id int64) {
+	name, indexed := c.indexedNames[id]
+	if !indexed {
+		return
+	}
+	delete(c.indexedNames, id)
+	if indexedID := c.nameIndex[name]; indexedID == id {
+		delete(c.nameIndex, name)
+	}
+}
+
+func (c *CachingCreatureRepo) saveSnapshot() error {
+	lister, lists := c.cache.(cacheEntryLister)
+	if !lists {
+		return errors.New("cache is unable to list its entries, so can't be snapshotted")
+	}
+	return writeCacheSnapshot(c.snapshotPath, cacheSnapshot{
+		Version: cacheSnapshotVersion,
+		SavedAt: c.clock.Now(),
+		Entries: lister.Entries(),
+	})
+}
+
+// restoreSnapshot caches the entries of a saved snapshot that are still of use. A snapshot that can't be read is
+// reported and otherwise ignored, leaving the repo to start out cold, as it would without one.
+func (c *CachingCreatureRepo) restoreSnapshot(ctx context.Context) {
+	snapshot, err := readCacheSnapshot(c.snapshotPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		c.logger.ErrorContext(ctx, "error restoring cache snapshot", "path", c.snapshotPath, "error", err)
+		return
+	}
+	now := c.clock.Now()
+	for id, entry := range snapshot.Entries {
+		if !entry.Result.ResultFound && c.negativeCacheDuration <= 0 {
+			continue
+		}
+		// the snapshot may have been taken with longer cache durations than are in use now
+		lifetime := c.negativeCacheDuration
+		if entry.Result.ResultFound {
+			lifetime = c.cacheDuration
+		}
+		if limit := entry.CachedAt.Add(lifetime); entry.ExpiresAt.After(limit) {
+			entry.ExpiresAt = limit
+		}
+		ttl := entry.ExpiresAt.Add(c.staleWindow).Sub(now)
+		if ttl <= 0 {
+			continue
+		}
+		shard := c.shard(id)
+		shard.mutex.Lock()
+		c.putLocked(ctx, id, entry, ttl)
+		shard.mutex.Unlock()
+	}
+}
+
+func (c *CachingCreatureRepo) shard(id int64) *repoShard {
+	return c.shards[shardIndex(id, len(c.shards))]
+}
+
+// clampFraction brings f within 0 to 1, treating NaN as zero
+func clampFraction(f float64) float64 {
+	if math.IsNaN(f) {
+		return 0
+	}
+	return min(max(f, 0), 1)
+}
+
+// markStaleLocked notes a change to the entry for an id, so that neither a load in flight for it nor a guarded read
+// started before the change caches what it read. Callers must hold the shard's lock.
+func markStaleLocked(shard *repoShard, id int64) {
+	if load, loading := shard.inflight[id]; loading {
+		load.stale = true
+	}
+	shard.changes++
+	if shard.readers > 0 {
+		shard.changedAt[id] = shard.changes
+	}
+}
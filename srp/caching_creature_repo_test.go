@@ -1,12 +1,25 @@
 package srp
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jonsabados/srp-sample/srp/srptest"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -154,12 +167,16 @@ func TestCachingCreatureRepo_GetCreature(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			ctx := context.Background()
 
-			testCacheDuration := time.Millisecond * 250
+			testCacheDuration := time.Minute
+			clock := srptest.NewManualClock(time.Now())
 
 			rawRepo := NewMockRawCreatureRepo(t)
 			rawRepo.EXPECT().GetCreature(mock.Anything, tc.expectedGetCreatureCall.inputID).Return(tc.expectedGetCreatureCall.result, tc.expectedGetCreatureCall.err).Once()
 
-			testInstance := NewCachingCreatureRepo(rawRepo, testCacheDuration)
+			testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+				CacheDuration: testCacheDuration,
+				Clock:         clock,
+			})
 
 			result, err := testInstance.GetCreature(ctx, tc.inputID)
 			assert.Equal(t, tc.expectedResult, result)
@@ -171,7 +188,13 @@ func TestCachingCreatureRepo_GetCreature(t *testing.T) {
 				assert.Equal(t, tc.expectedResult, result)
 				assert.Equal(t, tc.expectedErr, err)
 
-				time.Sleep(testCacheDuration)
+				// right up until the cache duration has passed things should still be served from cache
+				clock.Advance(testCacheDuration)
+				result, err = testInstance.GetCreature(ctx, tc.inputID)
+				assert.Equal(t, tc.expectedResult, result)
+				assert.Equal(t, tc.expectedErr, err)
+
+				clock.Advance(time.Nanosecond)
 				// now lets add the expected call to our mock and repeat to verify cache expiration works
 				rawRepo.EXPECT().GetCreature(mock.Anything, tc.expectedGetCreatureCall.inputID).Return(tc.expectedGetCreatureCall.result, tc.expectedGetCreatureCall.err).Once()
 				result, err = testInstance.GetCreature(ctx, tc.inputID)
@@ -217,3 +240,2683 @@ func TestCachingCreatureRepo_GetCreature_Concurrency(t *testing.T) {
 	barrier.Done()
 	wg.Wait()
 }
+
+func TestCachingCreatureRepo_GetCreatures(t *testing.T) {
+	bob := CreatureLookupResult{
+		ResultFound: true,
+		Creature: Creature{
+			ID:          1,
+			Name:        "bob",
+			Description: "likes testing",
+		},
+	}
+	alice := CreatureLookupResult{
+		ResultFound: true,
+		Creature: Creature{
+			ID:          2,
+			Name:        "alice",
+			Description: "also likes testing",
+		},
+	}
+	notFound := CreatureLookupResult{
+		ResultFound: false,
+	}
+
+	type getCreaturesCall struct {
+		inputIDs []int64
+		result   map[int64]CreatureLookupResult
+		err      error
+	}
+	testCases := []struct {
+		name                     string
+		inputIDs                 []int64
+		expectedGetCreaturesCall *getCreaturesCall
+		expectedResult           map[int64]CreatureLookupResult
+		expectResultCached       bool
+		expectedErr              error
+	}{
+		{
+			name:     "partially cached",
+			inputIDs: []int64{1, 2, 3, 2},
+			expectedGetCreaturesCall: &getCreaturesCall{
+				inputIDs: []int64{2, 3},
+				result: map[int64]CreatureLookupResult{
+					2: alice,
+					3: notFound,
+				},
+			},
+			expectedResult: map[int64]CreatureLookupResult{
+				1: bob,
+				2: alice,
+				3: notFound,
+			},
+			expectResultCached: true,
+		},
+		{
+			name:     "fully cached",
+			inputIDs: []int64{1, 1},
+			expectedResult: map[int64]CreatureLookupResult{
+				1: bob,
+			},
+			expectResultCached: true,
+		},
+		{
+			name:     "error retrieving",
+			inputIDs: []int64{1, 2, 3},
+			expectedGetCreaturesCall: &getCreaturesCall{
+				inputIDs: []int64{2, 3},
+				err:      errors.New("boom goes the DB"),
+			},
+			expectedErr: errors.New("boom goes the DB"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			rawRepo := NewMockRawCreatureRepo(t)
+			testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+			// bob is already known about
+			rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(bob, nil).Once()
+			_, err := testInstance.GetCreature(ctx, 1)
+			require.NoError(t, err)
+
+			if tc.expectedGetCreaturesCall != nil {
+				rawRepo.EXPECT().GetCreatures(mock.Anything, tc.expectedGetCreaturesCall.inputIDs).Return(tc.expectedGetCreaturesCall.result, tc.expectedGetCreaturesCall.err).Once()
+			}
+
+			result, err := testInstance.GetCreatures(ctx, tc.inputIDs)
+			assert.Equal(t, tc.expectedResult, result)
+			assert.Equal(t, tc.expectedErr, err)
+
+			if tc.expectResultCached {
+				// repeat the call without any further expectations on the raw repo to verify everything was cached
+				result, err := testInstance.GetCreatures(ctx, tc.inputIDs)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedResult, result)
+			}
+		})
+	}
+}
+
+func TestCachingCreatureRepo_GetCreatureByName(t *testing.T) {
+	type getCreatureByNameCall struct {
+		result CreatureLookupResult
+		err    error
+	}
+	testCases := []struct {
+		name                          string
+		inputName                     string
+		expectedGetCreatureByNameCall getCreatureByNameCall
+		expectedResult                CreatureLookupResult
+		expectResultCached            bool
+		expectedErr                   error
+	}{
+		{
+			name:      "happy path, found",
+			inputName: "bob",
+			expectedGetCreatureByNameCall: getCreatureByNameCall{
+				result: CreatureLookupResult{
+					ResultFound: true,
+					Creature: Creature{
+						ID:          123,
+						Name:        "bob",
+						Description: "likes testing",
+					},
+				},
+			},
+			expectedResult: CreatureLookupResult{
+				ResultFound: true,
+				Creature: Creature{
+					ID:          123,
+					Name:        "bob",
+					Description: "likes testing",
+				},
+			},
+			expectResultCached: true,
+		},
+		{
+			name:      "happy path, not found",
+			inputName: "bob",
+			expectedGetCreatureByNameCall: getCreatureByNameCall{
+				result: CreatureLookupResult{
+					ResultFound: false,
+				},
+			},
+			expectedResult: CreatureLookupResult{
+				ResultFound: false,
+			},
+			expectResultCached: false,
+		},
+		{
+			name:      "error retrieving",
+			inputName: "bob",
+			expectedGetCreatureByNameCall: getCreatureByNameCall{
+				err: errors.New("boom goes the DB"),
+			},
+			expectedErr:        errors.New("boom goes the DB"),
+			expectResultCached: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			rawRepo := NewMockRawCreatureRepo(t)
+			rawRepo.EXPECT().GetCreatureByName(mock.Anything, tc.inputName).Return(tc.expectedGetCreatureByNameCall.result, tc.expectedGetCreatureByNameCall.err).Once()
+
+			testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+			result, err := testInstance.GetCreatureByName(ctx, tc.inputName)
+			assert.Equal(t, tc.expectedResult, result)
+			assert.Equal(t, tc.expectedErr, err)
+
+			if tc.expectResultCached {
+				// both the name and id lookups should now be served without going to the raw repo
+				result, err := testInstance.GetCreatureByName(ctx, tc.inputName)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedResult, result)
+				result, err = testInstance.GetCreature(ctx, tc.expectedResult.Creature.ID)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedResult, result)
+			} else {
+				rawRepo.EXPECT().GetCreatureByName(mock.Anything, tc.inputName).Return(tc.expectedGetCreatureByNameCall.result, tc.expectedGetCreatureByNameCall.err).Once()
+				result, err := testInstance.GetCreatureByName(ctx, tc.inputName)
+				assert.Equal(t, tc.expectedResult, result)
+				assert.Equal(t, tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestCachingCreatureRepo_GetCreatureByName_IndexFollowsWrites(t *testing.T) {
+	ctx := context.Background()
+
+	bob := Creature{
+		ID:          123,
+		Name:        "bob",
+		Description: "likes testing",
+	}
+	robert := Creature{
+		ID:          123,
+		Name:        "robert",
+		Description: "likes testing",
+	}
+	newName := "robert"
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+	// creating should make the creature available by name
+	rawRepo.EXPECT().CreateCreature(mock.Anything, "bob", "likes testing").Return(bob, nil).Once()
+	_, err := testInstance.CreateCreature(ctx, "bob", "likes testing")
+	require.NoError(t, err)
+	result, err := testInstance.GetCreatureByName(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: bob}, result)
+
+	// renaming should move the index entry, with the old name going back to the raw repo
+	rawRepo.EXPECT().UpdateCreature(mock.Anything, int64(123), CreatureUpdate{Name: &newName}).Return(CreatureUpdateResult{ResultFound: true, Creature: robert}, nil).Once()
+	_, err = testInstance.UpdateCreature(ctx, 123, CreatureUpdate{Name: &newName})
+	require.NoError(t, err)
+	result, err = testInstance.GetCreatureByName(ctx, "robert")
+	require.NoError(t, err)
+	assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: robert}, result)
+	rawRepo.EXPECT().GetCreatureByName(mock.Anything, "bob").Return(CreatureLookupResult{ResultFound: false}, nil).Once()
+	result, err = testInstance.GetCreatureByName(ctx, "bob")
+	require.NoError(t, err)
+	assert.False(t, result.ResultFound)
+
+	// and deleting should drop the index entry entirely
+	rawRepo.EXPECT().DeleteCreature(mock.Anything, int64(123)).Return(CreatureDeleteResult{ResultFound: true}, nil).Once()
+	_, err = testInstance.DeleteCreature(ctx, 123)
+	require.NoError(t, err)
+	rawRepo.EXPECT().GetCreatureByName(mock.Anything, "robert").Return(CreatureLookupResult{ResultFound: false}, nil).Once()
+	result, err = testInstance.GetCreatureByName(ctx, "robert")
+	require.NoError(t, err)
+	assert.False(t, result.ResultFound)
+}
+
+func TestCachingCreatureRepo_UpdateCreature(t *testing.T) {
+	newName := "robert"
+	type updateCreatureCall struct {
+		result CreatureUpdateResult
+		err    error
+	}
+	testCases := []struct {
+		name                        string
+		inputID                     int64
+		inputUpdate                 CreatureUpdate
+		expectedUpdateCall          updateCreatureCall
+		expectedResult              CreatureUpdateResult
+		expectedErr                 error
+		expectedCachedLookup        CreatureLookupResult
+		expectCacheEntryInvalidated bool
+	}{
+		{
+			name:        "happy path, found",
+			inputID:     123,
+			inputUpdate: CreatureUpdate{Name: &newName},
+			expectedUpdateCall: updateCreatureCall{
+				result: CreatureUpdateResult{
+					ResultFound: true,
+					Creature: Creature{
+						ID:          123,
+						Name:        "robert",
+						Description: "likes testing",
+					},
+				},
+			},
+			expectedResult: CreatureUpdateResult{
+				ResultFound: true,
+				Creature: Creature{
+					ID:          123,
+					Name:        "robert",
+					Description: "likes testing",
+				},
+			},
+			expectedCachedLookup: CreatureLookupResult{
+				ResultFound: true,
+				Creature: Creature{
+					ID:          123,
+					Name:        "robert",
+					Description: "likes testing",
+				},
+			},
+		},
+		{
+			name:        "happy path, not found",
+			inputID:     123,
+			inputUpdate: CreatureUpdate{Name: &newName},
+			expectedUpdateCall: updateCreatureCall{
+				result: CreatureUpdateResult{
+					ResultFound: false,
+				},
+			},
+			expectedResult: CreatureUpdateResult{
+				ResultFound: false,
+			},
+			expectedCachedLookup: CreatureLookupResult{
+				ResultFound: false,
+			},
+		},
+		{
+			name:        "error updating",
+			inputID:     123,
+			inputUpdate: CreatureUpdate{Name: &newName},
+			expectedUpdateCall: updateCreatureCall{
+				err: errors.New("boom goes the DB"),
+			},
+			expectedErr:                 errors.New("boom goes the DB"),
+			expectCacheEntryInvalidated: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			rawRepo := NewMockRawCreatureRepo(t)
+			testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+			// prime the cache with the pre-update state of things
+			staleLookup := CreatureLookupResult{
+				ResultFound: true,
+				Creature: Creature{
+					ID:          tc.inputID,
+					Name:        "bob",
+					Description: "likes testing",
+				},
+			}
+			rawRepo.EXPECT().GetCreature(mock.Anything, tc.inputID).Return(staleLookup, nil).Once()
+			_, err := testInstance.GetCreature(ctx, tc.inputID)
+			require.NoError(t, err)
+
+			rawRepo.EXPECT().UpdateCreature(mock.Anything, tc.inputID, tc.inputUpdate).Return(tc.expectedUpdateCall.result, tc.expectedUpdateCall.err).Once()
+
+			result, err := testInstance.UpdateCreature(ctx, tc.inputID, tc.inputUpdate)
+			assert.Equal(t, tc.expectedResult, result)
+			assert.Equal(t, tc.expectedErr, err)
+
+			if tc.expectCacheEntryInvalidated {
+				// the cache should go back to the raw repo rather than serving the pre-update state
+				rawRepo.EXPECT().GetCreature(mock.Anything, tc.inputID).Return(staleLookup, nil).Once()
+				lookup, err := testInstance.GetCreature(ctx, tc.inputID)
+				assert.NoError(t, err)
+				assert.Equal(t, staleLookup, lookup)
+			} else {
+				// no expectation on the raw repo, so this must be served from the refreshed cache entry
+				lookup, err := testInstance.GetCreature(ctx, tc.inputID)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedCachedLookup, lookup)
+			}
+		})
+	}
+}
+
+func TestCachingCreatureRepo_DeleteCreature(t *testing.T) {
+	type deleteCreatureCall struct {
+		result CreatureDeleteResult
+		err    error
+	}
+	testCases := []struct {
+		name                        string
+		inputID                     int64
+		expectedDeleteCall          deleteCreatureCall
+		expectedResult              CreatureDeleteResult
+		expectedErr                 error
+		expectCacheEntryInvalidated bool
+	}{
+		{
+			name:    "happy path, found",
+			inputID: 123,
+			expectedDeleteCall: deleteCreatureCall{
+				result: CreatureDeleteResult{
+					ResultFound: true,
+				},
+			},
+			expectedResult: CreatureDeleteResult{
+				ResultFound: true,
+			},
+		},
+		{
+			name:    "happy path, not found",
+			inputID: 123,
+			expectedDeleteCall: deleteCreatureCall{
+				result: CreatureDeleteResult{
+					ResultFound: false,
+				},
+			},
+			expectedResult: CreatureDeleteResult{
+				ResultFound: false,
+			},
+		},
+		{
+			name:    "error deleting",
+			inputID: 123,
+			expectedDeleteCall: deleteCreatureCall{
+				err: errors.New("boom goes the DB"),
+			},
+			expectedErr:                 errors.New("boom goes the DB"),
+			expectCacheEntryInvalidated: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			rawRepo := NewMockRawCreatureRepo(t)
+			testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+			// prime the cache with the pre-delete state of things
+			staleLookup := CreatureLookupResult{
+				ResultFound: true,
+				Creature: Creature{
+					ID:          tc.inputID,
+					Name:        "bob",
+					Description: "likes testing",
+				},
+			}
+			rawRepo.EXPECT().GetCreature(mock.Anything, tc.inputID).Return(staleLookup, nil).Once()
+			_, err := testInstance.GetCreature(ctx, tc.inputID)
+			require.NoError(t, err)
+
+			rawRepo.EXPECT().DeleteCreature(mock.Anything, tc.inputID).Return(tc.expectedDeleteCall.result, tc.expectedDeleteCall.err).Once()
+
+			result, err := testInstance.DeleteCreature(ctx, tc.inputID)
+			assert.Equal(t, tc.expectedResult, result)
+			assert.Equal(t, tc.expectedErr, err)
+
+			if tc.expectCacheEntryInvalidated {
+				rawRepo.EXPECT().GetCreature(mock.Anything, tc.inputID).Return(staleLookup, nil).Once()
+				lookup, err := testInstance.GetCreature(ctx, tc.inputID)
+				assert.NoError(t, err)
+				assert.Equal(t, staleLookup, lookup)
+			} else {
+				lookup, err := testInstance.GetCreature(ctx, tc.inputID)
+				assert.NoError(t, err)
+				assert.Equal(t, CreatureLookupResult{ResultFound: false}, lookup)
+			}
+		})
+	}
+}
+
+func TestCachingCreatureRepo_ListCreatures(t *testing.T) {
+	type listCreaturesCall struct {
+		result CreaturePage
+		err    error
+	}
+	testCases := []struct {
+		name               string
+		inputOptions       ListOptions
+		expectedListCall   listCreaturesCall
+		expectedResult     CreaturePage
+		expectedErr        error
+		expectResultCached bool
+	}{
+		{
+			name: "happy path",
+			inputOptions: ListOptions{
+				PageSize: 2,
+				OrderBy:  OrderByName,
+			},
+			expectedListCall: listCreaturesCall{
+				result: CreaturePage{
+					Creatures: []Creature{
+						{ID: 1, Name: "alice", Description: "likes testing"},
+						{ID: 2, Name: "bob", Description: "also likes testing"},
+					},
+					NextCursor: "some-cursor",
+				},
+			},
+			expectedResult: CreaturePage{
+				Creatures: []Creature{
+					{ID: 1, Name: "alice", Description: "likes testing"},
+					{ID: 2, Name: "bob", Description: "also likes testing"},
+				},
+				NextCursor: "some-cursor",
+			},
+			expectResultCached: true,
+		},
+		{
+			name: "error listing",
+			inputOptions: ListOptions{
+				PageSize: 2,
+				OrderBy:  OrderByName,
+			},
+			expectedListCall: listCreaturesCall{
+				err: errors.New("boom goes the DB"),
+			},
+			expectedErr: errors.New("boom goes the DB"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			rawRepo := NewMockRawCreatureRepo(t)
+			// listings are never cached, so both calls should make it to the raw repo
+			rawRepo.EXPECT().ListCreatures(mock.Anything, tc.inputOptions).Return(tc.expectedListCall.result, tc.expectedListCall.err).Twice()
+
+			testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+			for i := 0; i < 2; i++ {
+				result, err := testInstance.ListCreatures(ctx, tc.inputOptions)
+				assert.Equal(t, tc.expectedResult, result)
+				assert.Equal(t, tc.expectedErr, err)
+			}
+
+			if tc.expectResultCached {
+				// no expectations for GetCreature on the raw repo, so these must come from the cache
+				for _, creature := range tc.expectedResult.Creatures {
+					lookup, err := testInstance.GetCreature(ctx, creature.ID)
+					assert.NoError(t, err)
+					assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: creature}, lookup)
+				}
+			}
+		})
+	}
+}
+
+func TestCachingCreatureRepo_PreservesErrors(t *testing.T) {
+	ctx := context.Background()
+	duplicate := &RepoError{
+		Kind:  ErrDuplicateName,
+		Cause: &pq.Error{Code: "23505", Constraint: "ux_creatures_name"},
+	}
+	unavailable := &RepoError{
+		Kind:  ErrUnavailable,
+		Cause: errors.New("connection refused"),
+	}
+	newName := "bob"
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().CreateCreature(mock.Anything, "bob", "likes testing").Return(Creature{}, duplicate).Once()
+	rawRepo.EXPECT().UpdateCreature(mock.Anything, int64(123), CreatureUpdate{Name: &newName}).Return(CreatureUpdateResult{}, duplicate).Once()
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(123)).Return(CreatureLookupResult{}, unavailable).Once()
+
+	testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+	_, err := testInstance.CreateCreature(ctx, "bob", "likes testing")
+	assert.Same(t, duplicate, err)
+	assert.ErrorIs(t, err, ErrDuplicateName)
+	var pqErr *pq.Error
+	assert.ErrorAs(t, err, &pqErr)
+
+	_, err = testInstance.UpdateCreature(ctx, 123, CreatureUpdate{Name: &newName})
+	assert.Same(t, duplicate, err)
+	assert.ErrorIs(t, err, ErrDuplicateName)
+
+	_, err = testInstance.GetCreature(ctx, 123)
+	assert.Same(t, unavailable, err)
+	assert.ErrorIs(t, err, ErrUnavailable)
+}
+
+func TestCachingCreatureRepo_GetCreature_MissDoesNotBlockOthers(t *testing.T) {
+	ctx := context.Background()
+
+	slowResult := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "slowpoke", Description: "takes a while to find"},
+	}
+	fastResult := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 2, Name: "speedy", Description: "found right away"},
+	}
+	cachedCreature := Creature{ID: 3, Name: "bob", Description: "already cached"}
+
+	loadStarted := make(chan struct{})
+	releaseLoad := make(chan struct{})
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).RunAndReturn(func(ctx context.Context, id int64) (CreatureLookupResult, error) {
+		close(loadStarted)
+		<-releaseLoad
+		return slowResult, nil
+	}).Once()
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(2)).Return(fastResult, nil).Once()
+	rawRepo.EXPECT().CreateCreature(mock.Anything, "bob", "already cached").Return(cachedCreature, nil).Once()
+
+	testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+	_, err := testInstance.CreateCreature(ctx, "bob", "already cached")
+	require.NoError(t, err)
+
+	slowDone := make(chan CreatureLookupResult)
+	go func() {
+		result, err := testInstance.GetCreature(ctx, 1)
+		assert.NoError(t, err)
+		slowDone <- result
+	}()
+	<-loadStarted
+
+	// with the slow lookup outstanding both a miss for a different id and a cache hit should complete
+	othersDone := make(chan struct{})
+	go func() {
+		defer close(othersDone)
+		result, err := testInstance.GetCreature(ctx, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, fastResult, result)
+		result, err = testInstance.GetCreature(ctx, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: cachedCreature}, result)
+	}()
+	select {
+	case <-othersDone:
+	case <-time.After(time.Second):
+		t.Fatal("lookups were blocked by an unrelated miss")
+	}
+
+	close(releaseLoad)
+	assert.Equal(t, slowResult, <-slowDone)
+}
+
+func TestCachingCreatureRepo_GetCreature_WriteDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	newName := "robert"
+
+	before := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+	after := CreatureUpdateResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "robert", Description: "likes testing"},
+	}
+
+	loadStarted := make(chan struct{})
+	releaseLoad := make(chan struct{})
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).RunAndReturn(func(ctx context.Context, id int64) (CreatureLookupResult, error) {
+		close(loadStarted)
+		<-releaseLoad
+		return before, nil
+	}).Once()
+	rawRepo.EXPECT().UpdateCreature(mock.Anything, int64(1), CreatureUpdate{Name: &newName}).Return(after, nil).Once()
+
+	testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+	loadDone := make(chan struct{})
+	go func() {
+		defer close(loadDone)
+		_, err := testInstance.GetCreature(ctx, 1)
+		assert.NoError(t, err)
+	}()
+	<-loadStarted
+
+	_, err := testInstance.UpdateCreature(ctx, 1, CreatureUpdate{Name: &newName})
+	require.NoError(t, err)
+	close(releaseLoad)
+	<-loadDone
+
+	// the load was started before the update so its result must not clobber the updated entry
+	result, err := testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: after.Creature}, result)
+}
+
+func TestCachingCreatureRepo_GetCreature_WaiterContextCancelled(t *testing.T) {
+	ctx := context.Background()
+
+	expectedResult := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+
+	loadStarted := make(chan struct{})
+	releaseLoad := make(chan struct{})
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).RunAndReturn(func(ctx context.Context, id int64) (CreatureLookupResult, error) {
+		close(loadStarted)
+		<-releaseLoad
+		return expectedResult, nil
+	}).Once()
+
+	testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+	loadDone := make(chan struct{})
+	go func() {
+		defer close(loadDone)
+		result, err := testInstance.GetCreature(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedResult, result)
+	}()
+	<-loadStarted
+
+	// a waiter that gives up should not take the outstanding load down with it
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := testInstance.GetCreature(cancelledCtx, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	close(releaseLoad)
+	<-loadDone
+	result, err := testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, expectedResult, result)
+}
+
+func TestCachingCreatureRepo_GetCreature_LeaderContextCancelled(t *testing.T) {
+	ctx := context.Background()
+
+	expectedResult := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+
+	loadStarted := make(chan struct{})
+	releaseLoad := make(chan struct{})
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).RunAndReturn(func(ctx context.Context, id int64) (CreatureLookupResult, error) {
+		close(loadStarted)
+		<-releaseLoad
+		// the caller that started the load having given up shouldn't have reached the raw repo
+		return expectedResult, ctx.Err()
+	}).Once()
+
+	testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		_, err := testInstance.GetCreature(leaderCtx, 1)
+		assert.ErrorIs(t, err, context.Canceled)
+	}()
+	<-loadStarted
+
+	waiterDone := make(chan struct{})
+	go func() {
+		defer close(waiterDone)
+		result, err := testInstance.GetCreature(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedResult, result)
+	}()
+	// give the waiter a chance to join the load before the leader goes away
+	assert.Eventually(t, func() bool {
+		return testInstance.Stats().Misses == 2
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-leaderDone
+	close(releaseLoad)
+	<-waiterDone
+
+	_, cached := testInstance.Peek(ctx, 1)
+	assert.True(t, cached)
+}
+
+// slowRawCreatureRepo answers every lookup after a delay, standing in for a database under load
+type slowRawCreatureRepo struct {
+	RawCreatureRepo
+	latency time.Duration
+}
+
+func (s *slowRawCreatureRepo) GetCreature(ctx context.Context, id int64) (CreatureLookupResult, error) {
+	time.Sleep(s.latency)
+	return CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: id, Name: fmt.Sprintf("creature_%d", id)},
+	}, nil
+}
+
+func BenchmarkCachingCreatureRepo_GetCreature_MixedLoad(b *testing.B) {
+	for _, hitRatio := range []float64{0.5, 0.9, 0.99} {
+		b.Run(fmt.Sprintf("hit ratio %.2f", hitRatio), func(b *testing.B) {
+			ctx := context.Background()
+			rawRepo := &slowRawCreatureRepo{}
+			testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+			cachedIDs := int64(1000)
+			for id := int64(0); id < cachedIDs; id++ {
+				_, err := testInstance.GetCreature(ctx, id)
+				require.NoError(b, err)
+			}
+			rawRepo.latency = time.Millisecond
+			// misses always ask for an id nobody has asked for before
+			var nextMiss atomic.Int64
+			nextMiss.Store(cachedIDs)
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				rnd := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					id := rnd.Int63n(cachedIDs)
+					if rnd.Float64() >= hitRatio {
+						id = nextMiss.Add(1)
+					}
+					_, err := testInstance.GetCreature(ctx, id)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestCachingCreatureRepo_MaxEntries(t *testing.T) {
+	testCases := []struct {
+		name           string
+		evictionPolicy EvictionPolicy
+		expectedKept   int64
+		expectedGone   int64
+	}{
+		{
+			name:           "lru",
+			evictionPolicy: EvictLRU,
+			expectedKept:   2,
+			expectedGone:   1,
+		},
+		{
+			name:           "lfu",
+			evictionPolicy: EvictLFU,
+			expectedKept:   1,
+			expectedGone:   2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			lookupResult := func(id int64) CreatureLookupResult {
+				return CreatureLookupResult{
+					ResultFound: true,
+					Creature:    Creature{ID: id, Name: fmt.Sprintf("creature_%d", id)},
+				}
+			}
+			rawRepo := NewMockRawCreatureRepo(t)
+			for _, id := range []int64{1, 2, 3} {
+				rawRepo.EXPECT().GetCreature(mock.Anything, id).Return(lookupResult(id), nil).Once()
+			}
+
+			testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+				CacheDuration:  time.Hour,
+				MaxEntries:     2,
+				EvictionPolicy: tc.evictionPolicy,
+			})
+
+			// 1 gets used a bunch early on, then 2 is used more recently, and finally 3 shows up and something has to go
+			for _, id := range []int64{1, 1, 1, 2, 2, 3} {
+				result, err := testInstance.GetCreature(ctx, id)
+				require.NoError(t, err)
+				assert.Equal(t, lookupResult(id), result)
+			}
+			stats := testInstance.Stats()
+			assert.Equal(t, 2, stats.Size)
+			assert.Equal(t, uint64(1), stats.Evictions)
+
+			// no further expectation for the kept entry, it should still be cached
+			result, err := testInstance.GetCreature(ctx, tc.expectedKept)
+			require.NoError(t, err)
+			assert.Equal(t, lookupResult(tc.expectedKept), result)
+
+			rawRepo.EXPECT().GetCreature(mock.Anything, tc.expectedGone).Return(lookupResult(tc.expectedGone), nil).Once()
+			result, err = testInstance.GetCreature(ctx, tc.expectedGone)
+			require.NoError(t, err)
+			assert.Equal(t, lookupResult(tc.expectedGone), result)
+		})
+	}
+}
+
+func TestCachingCreatureRepo_MaxEntries_KeepsNameIndexInStep(t *testing.T) {
+	ctx := context.Background()
+
+	bob := Creature{ID: 1, Name: "bob", Description: "likes testing"}
+	alice := Creature{ID: 2, Name: "alice", Description: "also likes testing"}
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().CreateCreature(mock.Anything, "bob", "likes testing").Return(bob, nil).Once()
+	rawRepo.EXPECT().CreateCreature(mock.Anything, "alice", "also likes testing").Return(alice, nil).Once()
+
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration: time.Hour,
+		MaxEntries:    1,
+	})
+
+	_, err := testInstance.CreateCreature(ctx, "bob", "likes testing")
+	require.NoError(t, err)
+	_, err = testInstance.CreateCreature(ctx, "alice", "also likes testing")
+	require.NoError(t, err)
+
+	// bob was evicted to make room for alice, so looking him up by name has to go to the raw repo
+	rawRepo.EXPECT().GetCreatureByName(mock.Anything, "bob").Return(CreatureLookupResult{ResultFound: true, Creature: bob}, nil).Once()
+	result, err := testInstance.GetCreatureByName(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: bob}, result)
+	assert.Equal(t, 1, testInstance.Stats().Size)
+}
+
+func TestCachingCreatureRepo_Janitor(t *testing.T) {
+	ctx := context.Background()
+
+	clock := srptest.NewManualClock(time.Now())
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(CreatureLookupResult{ResultFound: false}, nil).Once()
+
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration:   time.Minute,
+		JanitorInterval: time.Second,
+		Clock:           clock,
+	})
+	defer testInstance.Close()
+
+	_, err := testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	// the janitor only takes a tick once done sweeping for the one before, so two ticks see a sweep through. Nothing
+	// has expired yet so it should leave things be.
+	clock.Advance(time.Second)
+	clock.Advance(time.Second)
+	assert.Equal(t, 1, testInstance.Stats().Size)
+	assert.Equal(t, uint64(0), testInstance.Stats().Expirations)
+
+	clock.Advance(time.Minute)
+	clock.Advance(time.Second)
+	stats := testInstance.Stats()
+	assert.Equal(t, 0, stats.Size)
+	assert.Equal(t, uint64(1), stats.Expirations)
+
+	assert.NoError(t, testInstance.Close())
+	// closing more than once should be harmless
+	assert.NoError(t, testInstance.Close())
+	// and with the janitor gone the clock no longer waits on it
+	clock.Advance(time.Second)
+}
+
+func TestCachingCreatureRepo_NegativeCacheDuration(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		negativeCacheDuration time.Duration
+		expectNotFoundCached  bool
+	}{
+		{
+			name:                  "shorter negative cache duration",
+			negativeCacheDuration: time.Minute,
+			expectNotFoundCached:  true,
+		},
+		{
+			name:                  "negative caching disabled",
+			negativeCacheDuration: 0,
+			expectNotFoundCached:  false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			clock := srptest.NewManualClock(time.Now())
+
+			found := CreatureLookupResult{
+				ResultFound: true,
+				Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+			}
+			notFound := CreatureLookupResult{
+				ResultFound: false,
+			}
+
+			rawRepo := NewMockRawCreatureRepo(t)
+			rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(found, nil).Once()
+			rawRepo.EXPECT().GetCreature(mock.Anything, int64(2)).Return(notFound, nil).Once()
+
+			testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+				CacheDuration:         time.Hour,
+				NegativeCacheDuration: &tc.negativeCacheDuration,
+				Clock:                 clock,
+			})
+
+			result, err := testInstance.GetCreature(ctx, 1)
+			require.NoError(t, err)
+			assert.Equal(t, found, result)
+			result, err = testInstance.GetCreature(ctx, 2)
+			require.NoError(t, err)
+			assert.Equal(t, notFound, result)
+
+			if !tc.expectNotFoundCached {
+				rawRepo.EXPECT().GetCreature(mock.Anything, int64(2)).Return(notFound, nil).Once()
+			}
+			result, err = testInstance.GetCreature(ctx, 2)
+			require.NoError(t, err)
+			assert.Equal(t, notFound, result)
+
+			// once the negative cache duration passes the not found result should be re-queried, while the found
+			// result remains cached
+			clock.Advance(tc.negativeCacheDuration + time.Nanosecond)
+			rawRepo.EXPECT().GetCreature(mock.Anything, int64(2)).Return(found, nil).Once()
+			result, err = testInstance.GetCreature(ctx, 2)
+			require.NoError(t, err)
+			assert.Equal(t, found, result)
+			result, err = testInstance.GetCreature(ctx, 1)
+			require.NoError(t, err)
+			assert.Equal(t, found, result)
+		})
+	}
+}
+
+func TestCachingCreatureRepo_NegativeCachingDisabled_DeleteDropsEntry(t *testing.T) {
+	ctx := context.Background()
+	disabled := time.Duration(0)
+
+	found := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(found, nil).Once()
+	rawRepo.EXPECT().DeleteCreature(mock.Anything, int64(1)).Return(CreatureDeleteResult{ResultFound: true}, nil).Once()
+
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration:         time.Hour,
+		NegativeCacheDuration: &disabled,
+	})
+
+	_, err := testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	_, err = testInstance.DeleteCreature(ctx, 1)
+	require.NoError(t, err)
+
+	// with nothing to remember the deletion by the cache must not keep serving the pre-delete entry
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(CreatureLookupResult{ResultFound: false}, nil).Once()
+	result, err := testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.False(t, result.ResultFound)
+	assert.Equal(t, 0, testInstance.Stats().Size)
+}
+
+func TestCachingCreatureRepo_StaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+	clock := srptest.NewManualClock(time.Now())
+
+	original := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+	refreshed := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing a bit less"},
+	}
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(original, nil).Once()
+
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration: time.Minute,
+		StaleWindow:   time.Minute,
+		Clock:         clock,
+	})
+
+	result, err := testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, original, result)
+
+	// the refresh is held up until we've made sure stale reads don't wait on it, or kick off refreshes of their own
+	release := make(chan struct{})
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).RunAndReturn(func(ctx context.Context, id int64) (CreatureLookupResult, error) {
+		<-release
+		return refreshed, nil
+	}).Once()
+
+	clock.Advance(time.Minute + time.Second)
+	for i := 0; i < 5; i++ {
+		result, err = testInstance.GetCreature(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, original, result)
+	}
+	assert.Equal(t, uint64(5), testInstance.Stats().StaleHits)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		result, err := testInstance.GetCreature(ctx, 1)
+		return err == nil && result == refreshed
+	}, time.Second, time.Millisecond)
+}
+
+func TestCachingCreatureRepo_StaleWhileRevalidate_BeyondStaleWindow(t *testing.T) {
+	ctx := context.Background()
+	clock := srptest.NewManualClock(time.Now())
+
+	original := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+	reloaded := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing a bit less"},
+	}
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(original, nil).Once()
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(reloaded, nil).Once()
+
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration: time.Minute,
+		StaleWindow:   time.Minute,
+		Clock:         clock,
+	})
+
+	result, err := testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, original, result)
+
+	clock.Advance(2*time.Minute + time.Nanosecond)
+	result, err = testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, reloaded, result)
+	assert.Equal(t, uint64(0), testInstance.Stats().StaleHits)
+}
+
+func TestCachingCreatureRepo_StaleWhileRevalidate_RefreshError(t *testing.T) {
+	ctx := context.Background()
+	clock := srptest.NewManualClock(time.Now())
+	logs := &bytes.Buffer{}
+
+	original := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(original, nil).Once()
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(CreatureLookupResult{}, errors.New("database is napping")).Once()
+
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration: time.Minute,
+		StaleWindow:   time.Minute,
+		Clock:         clock,
+		Logger:        slog.New(slog.NewTextHandler(&lockedWriter{w: logs}, nil)),
+	})
+
+	result, err := testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, original, result)
+
+	clock.Advance(time.Minute + time.Second)
+	result, err = testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, original, result)
+
+	assert.Eventually(t, func() bool {
+		return testInstance.Stats().RefreshErrors == 1
+	}, time.Second, time.Millisecond)
+
+	// the failed refresh should leave the stale entry in place, and a later read should try again
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(original, nil).Once()
+	result, err = testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, original, result)
+	assert.Equal(t, uint64(2), testInstance.Stats().StaleHits)
+	// once the refresh lands reads are fresh hits again
+	assert.Eventually(t, func() bool {
+		staleHits := testInstance.Stats().StaleHits
+		_, err := testInstance.GetCreature(ctx, 1)
+		return err == nil && testInstance.Stats().StaleHits == staleHits
+	}, time.Second, time.Millisecond)
+
+	output := logs.String()
+	assert.Contains(t, output, "error refreshing cached creature")
+	assert.Contains(t, output, "database is napping")
+}
+
+// lockedWriter lets a log handler write from background goroutines while the test reads what was written
+type lockedWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.w.Write(p)
+}
+
+func TestCachingCreatureRepo_RefreshAhead(t *testing.T) {
+	ctx := context.Background()
+	clock := srptest.NewManualClock(time.Now())
+
+	original := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+	refreshed := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing a bit less"},
+	}
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(original, nil).Once()
+
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration:         10 * time.Minute,
+		RefreshAheadThreshold: 0.2,
+		Clock:                 clock,
+	})
+
+	result, err := testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, original, result)
+
+	// hits before the last 20% of the entry's lifetime shouldn't do anything special
+	clock.Advance(7 * time.Minute)
+	result, err = testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, original, result)
+	assert.Equal(t, uint64(0), testInstance.Stats().RefreshAheadHits)
+
+	release := make(chan struct{})
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).RunAndReturn(func(ctx context.Context, id int64) (CreatureLookupResult, error) {
+		<-release
+		return refreshed, nil
+	}).Once()
+
+	clock.Advance(2 * time.Minute)
+	for i := 0; i < 3; i++ {
+		result, err = testInstance.GetCreature(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, original, result)
+	}
+	stats := testInstance.Stats()
+	assert.Equal(t, uint64(3), stats.RefreshAheadHits)
+	assert.Equal(t, uint64(1), stats.Misses)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		result, err := testInstance.GetCreature(ctx, 1)
+		return err == nil && result == refreshed
+	}, time.Second, time.Millisecond)
+	// the refreshed entry starts a new lifetime, so it is well clear of the threshold
+	refreshAheadHits := testInstance.Stats().RefreshAheadHits
+	_, err = testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, refreshAheadHits, testInstance.Stats().RefreshAheadHits)
+}
+
+func TestCachingCreatureRepo_TTLJitter(t *testing.T) {
+	ctx := context.Background()
+	clock := srptest.NewManualClock(time.Now())
+
+	ids := make([]int64, 100)
+	fetched := make(map[int64]CreatureLookupResult, len(ids))
+	for i := range ids {
+		ids[i] = int64(i + 1)
+		fetched[ids[i]] = CreatureLookupResult{
+			ResultFound: true,
+			Creature:    Creature{ID: ids[i], Name: fmt.Sprintf("creature %d", ids[i]), Description: "cached in bulk"},
+		}
+	}
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreatures(mock.Anything, ids).Return(fetched, nil).Once()
+
+	cache := NewMemoryCreatureCache(MemoryCreatureCacheOptions{
+		Clock: clock,
+	})
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration: 10 * time.Minute,
+		TTLJitter:     0.5,
+		Cache:         cache,
+		Clock:         clock,
+	})
+
+	_, err := testInstance.GetCreatures(ctx, ids)
+	require.NoError(t, err)
+
+	// every entry lives for at least half the cache duration
+	clock.Advance(5 * time.Minute)
+	cache.sweepExpired()
+	assert.Equal(t, uint64(0), cache.Stats().Expirations)
+
+	// with 100 entries the odds of none, or all, of them having expired by now are vanishingly small
+	clock.Advance(150 * time.Second)
+	cache.sweepExpired()
+	expirations := cache.Stats().Expirations
+	assert.Greater(t, expirations, uint64(0))
+	assert.Less(t, expirations, uint64(len(ids)))
+
+	// and none of them outlive the cache duration
+	clock.Advance(150*time.Second + time.Nanosecond)
+	cache.sweepExpired()
+	assert.Equal(t, uint64(len(ids)), cache.Stats().Expirations)
+}
+
+func TestCachingCreatureRepo_TTLJitter_OutOfRange(t *testing.T) {
+	ctx := context.Background()
+	clock := srptest.NewManualClock(time.Now())
+
+	ids := make([]int64, 100)
+	fetched := make(map[int64]CreatureLookupResult, len(ids))
+	for i := range ids {
+		ids[i] = int64(i + 1)
+		fetched[ids[i]] = CreatureLookupResult{
+			ResultFound: true,
+			Creature:    Creature{ID: ids[i], Name: fmt.Sprintf("creature %d", ids[i])},
+		}
+	}
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreatures(mock.Anything, ids).Return(fetched, nil).Once()
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration: 10 * time.Minute,
+		TTLJitter:     5,
+		Clock:         clock,
+	})
+
+	_, err := testInstance.GetCreatures(ctx, ids)
+	require.NoError(t, err)
+
+	// jitter is capped at the whole cache duration, so nothing is expired before it is even cached
+	for _, id := range ids {
+		entry, cached := testInstance.Peek(ctx, id)
+		require.True(t, cached)
+		assert.True(t, entry.ExpiresAt.After(entry.CachedAt), "id %d", id)
+	}
+}
+
+func TestCachingCreatureRepo_RefreshAhead_OutOfRange(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name                     string
+		threshold                float64
+		expectedRefreshAheadHits uint64
+	}{
+		{
+			name:                     "negative",
+			threshold:                -0.5,
+			expectedRefreshAheadHits: 0,
+		},
+		{
+			name:                     "more than one",
+			threshold:                5,
+			expectedRefreshAheadHits: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := srptest.NewManualClock(time.Now())
+			bob := CreatureLookupResult{
+				ResultFound: true,
+				Creature:    Creature{ID: 1, Name: "bob"},
+			}
+			rawRepo := NewMockRawCreatureRepo(t)
+			rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(bob, nil).Times(1 + int(tc.expectedRefreshAheadHits))
+			testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+				CacheDuration:         time.Minute,
+				RefreshAheadThreshold: tc.threshold,
+				Clock:                 clock,
+			})
+
+			_, err := testInstance.GetCreature(ctx, 1)
+			require.NoError(t, err)
+			// a hit a moment after caching, which is only refreshed when refreshing on every hit
+			clock.Advance(time.Second)
+			_, err = testInstance.GetCreature(ctx, 1)
+			require.NoError(t, err)
+			assert.Eventually(t, func() bool {
+				return testInstance.Stats().Loads == 1+tc.expectedRefreshAheadHits
+			}, time.Second, time.Millisecond)
+			assert.Equal(t, tc.expectedRefreshAheadHits, testInstance.Stats().RefreshAheadHits)
+		})
+	}
+}
+
+func TestCachingCreatureRepo_SharedRedisCache(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	bob := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+
+	// two repos, as if in two different processes, sharing the one redis
+	firstRawRepo := NewMockRawCreatureRepo(t)
+	firstRawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(bob, nil).Once()
+	first := NewCachingCreatureRepoWithOptions(firstRawRepo, CachingCreatureRepoOptions{
+		CacheDuration: time.Minute,
+		Cache:         NewRedisCreatureCache(client, ""),
+	})
+	secondRawRepo := NewMockRawCreatureRepo(t)
+	second := NewCachingCreatureRepoWithOptions(secondRawRepo, CachingCreatureRepoOptions{
+		CacheDuration: time.Minute,
+		Cache:         NewRedisCreatureCache(client, ""),
+	})
+
+	result, err := first.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, bob, result)
+
+	// no expectations on the second raw repo, what the first cached should be good enough
+	result, err = second.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, bob, result)
+
+	// and writes through one are seen by the other
+	renamed := "robert"
+	updated := Creature{ID: 1, Name: renamed, Description: "likes testing"}
+	secondRawRepo.EXPECT().UpdateCreature(mock.Anything, int64(1), CreatureUpdate{Name: &renamed}).Return(CreatureUpdateResult{ResultFound: true, Creature: updated}, nil).Once()
+	_, err = second.UpdateCreature(ctx, 1, CreatureUpdate{Name: &renamed})
+	require.NoError(t, err)
+	result, err = first.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: updated}, result)
+}
+
+func TestCachingCreatureRepo_CacheUnavailable(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	// the server is going away for good, so there is no point in retrying
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1, DialerRetries: 1})
+	defer client.Close()
+	logs := &bytes.Buffer{}
+
+	bob := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(bob, nil).Twice()
+
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration: time.Minute,
+		Cache:         NewRedisCreatureCache(client, ""),
+		Logger:        slog.New(slog.NewTextHandler(logs, nil)),
+	})
+
+	server.Close()
+
+	// with the cache gone every lookup has to go to the raw repo, but lookups should still work
+	for i := 0; i < 2; i++ {
+		result, err := testInstance.GetCreature(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, bob, result)
+	}
+	assert.NotZero(t, testInstance.Stats().CacheErrors)
+	assert.Contains(t, logs.String(), "error reading cached creature")
+}
+
+func TestCachingCreatureRepo_Shards(t *testing.T) {
+	ctx := context.Background()
+
+	lookupResult := func(id int64) CreatureLookupResult {
+		return CreatureLookupResult{
+			ResultFound: true,
+			Creature:    Creature{ID: id, Name: fmt.Sprintf("creature_%d", id)},
+		}
+	}
+	ids := 64
+	rawRepo := NewMockRawCreatureRepo(t)
+	for id := int64(0); id < int64(ids); id++ {
+		rawRepo.EXPECT().GetCreature(mock.Anything, id).Return(lookupResult(id), nil).Once()
+	}
+
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration: time.Hour,
+		Shards:        8,
+	})
+	require.IsType(t, &ShardedMemoryCreatureCache{}, testInstance.cache)
+
+	// hammer every id from a bunch of goroutines at once, each id should still only be loaded the once
+	barrier := sync.WaitGroup{}
+	barrier.Add(1)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			barrier.Wait()
+			for id := int64(0); id < int64(ids); id++ {
+				result, err := testInstance.GetCreature(ctx, id)
+				require.NoError(t, err)
+				assert.Equal(t, lookupResult(id), result)
+			}
+		}()
+	}
+	barrier.Done()
+	wg.Wait()
+
+	assert.Equal(t, ids, testInstance.Stats().Size)
+}
+
+func TestCachingCreatureRepo_Invalidate(t *testing.T) {
+	ctx := context.Background()
+
+	lookupResult := func(id int64) CreatureLookupResult {
+		return CreatureLookupResult{
+			ResultFound: true,
+			Creature:    Creature{ID: id, Name: fmt.Sprintf("creature_%d", id)},
+		}
+	}
+	rawRepo := NewMockRawCreatureRepo(t)
+	testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+	for _, id := range []int64{1, 2} {
+		rawRepo.EXPECT().GetCreature(mock.Anything, id).Return(lookupResult(id), nil).Once()
+		_, err := testInstance.GetCreature(ctx, id)
+		require.NoError(t, err)
+	}
+
+	testInstance.Invalidate(ctx, 1)
+	_, cached := testInstance.Peek(ctx, 1)
+	assert.False(t, cached)
+	_, cached = testInstance.Peek(ctx, 2)
+	assert.True(t, cached)
+
+	// both lookups by id and by name should now go to the raw repo
+	rawRepo.EXPECT().GetCreatureByName(mock.Anything, "creature_1").Return(lookupResult(1), nil).Once()
+	result, err := testInstance.GetCreatureByName(ctx, "creature_1")
+	require.NoError(t, err)
+	assert.Equal(t, lookupResult(1), result)
+
+	testInstance.Invalidate(ctx, 1)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(lookupResult(1), nil).Once()
+	result, err = testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, lookupResult(1), result)
+
+	// invalidating what isn't cached is fine
+	testInstance.Invalidate(ctx, 3)
+}
+
+func TestCachingCreatureRepo_Invalidate_DuringLoad(t *testing.T) {
+	ctx := context.Background()
+
+	before := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+
+	loadStarted := make(chan struct{})
+	releaseLoad := make(chan struct{})
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).RunAndReturn(func(ctx context.Context, id int64) (CreatureLookupResult, error) {
+		close(loadStarted)
+		<-releaseLoad
+		return before, nil
+	}).Once()
+
+	testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+	loadDone := make(chan struct{})
+	go func() {
+		defer close(loadDone)
+		_, err := testInstance.GetCreature(ctx, 1)
+		assert.NoError(t, err)
+	}()
+	<-loadStarted
+
+	testInstance.Invalidate(ctx, 1)
+	close(releaseLoad)
+	<-loadDone
+
+	// the load may have read from before whatever led to the invalidation, so what it read shouldn't have been cached
+	_, cached := testInstance.Peek(ctx, 1)
+	assert.False(t, cached)
+}
+
+func TestCachingCreatureRepo_ChangeDuringRead(t *testing.T) {
+	ctx := context.Background()
+	before := Creature{ID: 1, Name: "bob", Description: "likes testing"}
+	after := Creature{ID: 1, Name: "bob", Description: "likes reviewing"}
+	alice := Creature{ID: 2, Name: "alice", Description: "likes testing"}
+	newDescription := after.Description
+
+	reads := []struct {
+		name string
+		// expect sets up the raw repo to block on release before returning what it read, which includes bob as he was
+		// before the change, and alice if unaffected is set
+		expect func(rawRepo *MockRawCreatureRepo, started chan<- struct{}, release <-chan struct{}) (unaffected bool)
+		read   func(testInstance *CachingCreatureRepo) error
+	}{
+		{
+			name: "GetCreatures",
+			expect: func(rawRepo *MockRawCreatureRepo, started chan<- struct{}, release <-chan struct{}) bool {
+				rawRepo.EXPECT().GetCreatures(mock.Anything, []int64{1, 2}).RunAndReturn(func(context.Context, []int64) (map[int64]CreatureLookupResult, error) {
+					close(started)
+					<-release
+					return map[int64]CreatureLookupResult{
+						1: {ResultFound: true, Creature: before},
+						2: {ResultFound: true, Creature: alice},
+					}, nil
+				}).Once()
+				return true
+			},
+			read: func(testInstance *CachingCreatureRepo) error {
+				_, err := testInstance.GetCreatures(ctx, []int64{1, 2})
+				return err
+			},
+		},
+		{
+			name: "ListCreatures",
+			expect: func(rawRepo *MockRawCreatureRepo, started chan<- struct{}, release <-chan struct{}) bool {
+				rawRepo.EXPECT().ListCreatures(mock.Anything, ListOptions{}).RunAndReturn(func(context.Context, ListOptions) (CreaturePage, error) {
+					close(started)
+					<-release
+					return CreaturePage{Creatures: []Creature{before, alice}}, nil
+				}).Once()
+				return true
+			},
+			read: func(testInstance *CachingCreatureRepo) error {
+				_, err := testInstance.ListCreatures(ctx, ListOptions{})
+				return err
+			},
+		},
+		{
+			name: "GetCreatureByName",
+			expect: func(rawRepo *MockRawCreatureRepo, started chan<- struct{}, release <-chan struct{}) bool {
+				rawRepo.EXPECT().GetCreatureByName(mock.Anything, "bob").RunAndReturn(func(context.Context, string) (CreatureLookupResult, error) {
+					close(started)
+					<-release
+					return CreatureLookupResult{ResultFound: true, Creature: before}, nil
+				}).Once()
+				return false
+			},
+			read: func(testInstance *CachingCreatureRepo) error {
+				_, err := testInstance.GetCreatureByName(ctx, "bob")
+				return err
+			},
+		},
+	}
+	changes := []struct {
+		name   string
+		expect func(rawRepo *MockRawCreatureRepo)
+		change func(testInstance *CachingCreatureRepo)
+		// expected is what should be cached for bob afterward, nil meaning nothing
+		expected *CreatureLookupResult
+		// clearsAll is set for changes that drop alice too
+		clearsAll bool
+	}{
+		{
+			name: "Invalidate",
+			change: func(testInstance *CachingCreatureRepo) {
+				testInstance.Invalidate(ctx, 1)
+			},
+		},
+		{
+			name: "InvalidateAll",
+			change: func(testInstance *CachingCreatureRepo) {
+				testInstance.InvalidateAll(ctx)
+			},
+			clearsAll: true,
+		},
+		{
+			name: "UpdateCreature",
+			expect: func(rawRepo *MockRawCreatureRepo) {
+				rawRepo.EXPECT().UpdateCreature(mock.Anything, int64(1), CreatureUpdate{Description: &newDescription}).Return(CreatureUpdateResult{ResultFound: true, Creature: after}, nil).Once()
+			},
+			change: func(testInstance *CachingCreatureRepo) {
+				_, err := testInstance.UpdateCreature(ctx, 1, CreatureUpdate{Description: &newDescription})
+				assert.NoError(t, err)
+			},
+			expected: &CreatureLookupResult{ResultFound: true, Creature: after},
+		},
+		{
+			name: "DeleteCreature",
+			expect: func(rawRepo *MockRawCreatureRepo) {
+				rawRepo.EXPECT().DeleteCreature(mock.Anything, int64(1)).Return(CreatureDeleteResult{ResultFound: true}, nil).Once()
+			},
+			change: func(testInstance *CachingCreatureRepo) {
+				_, err := testInstance.DeleteCreature(ctx, 1)
+				assert.NoError(t, err)
+			},
+			expected: &CreatureLookupResult{ResultFound: false},
+		},
+	}
+	for _, read := range reads {
+		for _, change := range changes {
+			t.Run(fmt.Sprintf("%s during %s", change.name, read.name), func(t *testing.T) {
+				rawRepo := NewMockRawCreatureRepo(t)
+				started := make(chan struct{})
+				release := make(chan struct{})
+				unaffected := read.expect(rawRepo, started, release)
+				if change.expect != nil {
+					change.expect(rawRepo)
+				}
+				testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+					CacheDuration: time.Hour,
+					Shards:        2,
+				})
+
+				readDone := make(chan struct{})
+				go func() {
+					defer close(readDone)
+					assert.NoError(t, read.read(testInstance))
+				}()
+				<-started
+				change.change(testInstance)
+				close(release)
+				<-readDone
+
+				// the read may have seen bob from before the change, so it mustn't have replaced whatever the change left
+				entry, cached := testInstance.Peek(ctx, 1)
+				if change.expected == nil {
+					assert.False(t, cached)
+				} else {
+					assert.True(t, cached)
+					assert.Equal(t, *change.expected, entry.Result)
+				}
+				if unaffected {
+					// alice wasn't changed, so is cached as read, unless everything was dropped before the read came back
+					_, cached := testInstance.Peek(ctx, 2)
+					assert.Equal(t, !change.clearsAll, cached)
+				}
+			})
+		}
+	}
+}
+
+func TestCachingCreatureRepo_InvalidateAll(t *testing.T) {
+	ctx := context.Background()
+
+	lookupResult := func(id int64) CreatureLookupResult {
+		return CreatureLookupResult{
+			ResultFound: true,
+			Creature:    Creature{ID: id, Name: fmt.Sprintf("creature_%d", id)},
+		}
+	}
+	rawRepo := NewMockRawCreatureRepo(t)
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration: time.Hour,
+		Shards:        4,
+	})
+	for id := int64(0); id < 10; id++ {
+		rawRepo.EXPECT().GetCreature(mock.Anything, id).Return(lookupResult(id), nil).Once()
+		_, err := testInstance.GetCreature(ctx, id)
+		require.NoError(t, err)
+	}
+
+	testInstance.InvalidateAll(ctx)
+	assert.Equal(t, 0, testInstance.Stats().Size)
+
+	rawRepo.EXPECT().GetCreatureByName(mock.Anything, "creature_3").Return(lookupResult(3), nil).Once()
+	result, err := testInstance.GetCreatureByName(ctx, "creature_3")
+	require.NoError(t, err)
+	assert.Equal(t, lookupResult(3), result)
+}
+
+func TestCachingCreatureRepo_Peek(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 3, 14, 15, 9, 26, 0, time.UTC)
+
+	found := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+
+	testCases := []struct {
+		name          string
+		cache         bool
+		advance       time.Duration
+		expectedFound bool
+	}{
+		{
+			name:          "not cached",
+			expectedFound: false,
+		},
+		{
+			name:          "fresh",
+			cache:         true,
+			advance:       time.Minute,
+			expectedFound: true,
+		},
+		{
+			name:          "expired but within stale window",
+			cache:         true,
+			advance:       time.Minute + time.Second,
+			expectedFound: true,
+		},
+		{
+			name:          "beyond stale window",
+			cache:         true,
+			advance:       2*time.Minute + time.Second,
+			expectedFound: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := srptest.NewManualClock(start)
+			// peeking must never go to the raw repo, which the mock will complain about should it happen
+			rawRepo := NewMockRawCreatureRepo(t)
+			testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+				CacheDuration: time.Minute,
+				StaleWindow:   time.Minute,
+				Clock:         clock,
+			})
+			if tc.cache {
+				rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(found, nil).Once()
+				_, err := testInstance.GetCreature(ctx, 1)
+				require.NoError(t, err)
+			}
+			clock.Advance(tc.advance)
+			misses := testInstance.Stats().Misses
+
+			entry, cached := testInstance.Peek(ctx, 1)
+			assert.Equal(t, tc.expectedFound, cached)
+			if tc.expectedFound {
+				assert.Equal(t, CreatureCacheEntry{
+					Result:    found,
+					CachedAt:  start,
+					ExpiresAt: start.Add(time.Minute),
+				}, entry)
+			}
+			assert.Equal(t, misses, testInstance.Stats().Misses)
+		})
+	}
+}
+
+func TestCachingCreatureRepo_WithCacheBypass(t *testing.T) {
+	ctx := context.Background()
+
+	original := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+	changed := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing a bit less"},
+	}
+	other := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 2, Name: "alice", Description: "likes reviewing"},
+	}
+
+	testCases := []struct {
+		name   string
+		bypass func(t *testing.T, rawRepo *MockRawCreatureRepo, testInstance *CachingCreatureRepo)
+	}{
+		{
+			name: "GetCreature",
+			bypass: func(t *testing.T, rawRepo *MockRawCreatureRepo, testInstance *CachingCreatureRepo) {
+				rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(changed, nil).Once()
+				result, err := testInstance.GetCreature(WithCacheBypass(ctx), 1)
+				require.NoError(t, err)
+				assert.Equal(t, changed, result)
+			},
+		},
+		{
+			name: "GetCreatures",
+			bypass: func(t *testing.T, rawRepo *MockRawCreatureRepo, testInstance *CachingCreatureRepo) {
+				rawRepo.EXPECT().GetCreatures(mock.Anything, []int64{1, 2}).Return(map[int64]CreatureLookupResult{1: changed, 2: other}, nil).Once()
+				result, err := testInstance.GetCreatures(WithCacheBypass(ctx), []int64{1, 2})
+				require.NoError(t, err)
+				assert.Equal(t, map[int64]CreatureLookupResult{1: changed, 2: other}, result)
+			},
+		},
+		{
+			name: "GetCreatureByName",
+			bypass: func(t *testing.T, rawRepo *MockRawCreatureRepo, testInstance *CachingCreatureRepo) {
+				rawRepo.EXPECT().GetCreatureByName(mock.Anything, "bob").Return(changed, nil).Once()
+				result, err := testInstance.GetCreatureByName(WithCacheBypass(ctx), "bob")
+				require.NoError(t, err)
+				assert.Equal(t, changed, result)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rawRepo := NewMockRawCreatureRepo(t)
+			rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(original, nil).Once()
+			testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+			_, err := testInstance.GetCreature(ctx, 1)
+			require.NoError(t, err)
+
+			tc.bypass(t, rawRepo, testInstance)
+
+			// whatever the bypass read should have replaced the cached entry
+			result, err := testInstance.GetCreature(ctx, 1)
+			require.NoError(t, err)
+			assert.Equal(t, changed, result)
+		})
+	}
+}
+
+func TestCachingCreatureRepo_WithCacheBypass_Error(t *testing.T) {
+	ctx := context.Background()
+
+	original := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(original, nil).Once()
+	testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+	_, err := testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(CreatureLookupResult{}, errors.New("some DB error here")).Once()
+	_, err = testInstance.GetCreature(WithCacheBypass(ctx), 1)
+	assert.Equal(t, errors.New("some DB error here"), err)
+
+	// a failed bypass leaves what was cached alone
+	result, err := testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, original, result)
+}
+
+func TestCachingCreatureRepo_WithCacheBypass_DuringLoad(t *testing.T) {
+	ctx := context.Background()
+
+	before := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+	after := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing a bit less"},
+	}
+
+	loadStarted := make(chan struct{})
+	releaseLoad := make(chan struct{})
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).RunAndReturn(func(ctx context.Context, id int64) (CreatureLookupResult, error) {
+		close(loadStarted)
+		<-releaseLoad
+		return before, nil
+	}).Once()
+
+	testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+	loadDone := make(chan struct{})
+	go func() {
+		defer close(loadDone)
+		result, err := testInstance.GetCreature(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, before, result)
+	}()
+	<-loadStarted
+
+	// the bypass shouldn't wait on the load already in flight, as that may have read from before what it is after
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(after, nil).Once()
+	result, err := testInstance.GetCreature(WithCacheBypass(ctx), 1)
+	require.NoError(t, err)
+	assert.Equal(t, after, result)
+
+	close(releaseLoad)
+	<-loadDone
+	result, err = testInstance.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, after, result)
+}
+
+func TestCachingCreatureRepo_Stats(t *testing.T) {
+	ctx := context.Background()
+	clock := srptest.NewManualClock(time.Now())
+
+	found := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+	notFound := CreatureLookupResult{ResultFound: false}
+	// each load takes time on the clock, so that latency can be checked
+	slowly := func(result CreatureLookupResult, latency time.Duration, err error) func(ctx context.Context, id int64) (CreatureLookupResult, error) {
+		return func(ctx context.Context, id int64) (CreatureLookupResult, error) {
+			clock.Advance(latency)
+			return result, err
+		}
+	}
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).RunAndReturn(slowly(found, 10*time.Millisecond, nil)).Once()
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(2)).RunAndReturn(slowly(notFound, 20*time.Millisecond, nil)).Once()
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(3)).RunAndReturn(slowly(CreatureLookupResult{}, 30*time.Millisecond, errors.New("some DB error here"))).Once()
+	rawRepo.EXPECT().GetCreatures(mock.Anything, []int64{4}).RunAndReturn(func(ctx context.Context, ids []int64) (map[int64]CreatureLookupResult, error) {
+		clock.Advance(40 * time.Millisecond)
+		return map[int64]CreatureLookupResult{4: notFound}, nil
+	}).Once()
+
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration: time.Hour,
+		Clock:         clock,
+	})
+	assert.Equal(t, float64(0), testInstance.Stats().HitRatio())
+	assert.Equal(t, time.Duration(0), testInstance.Stats().AverageLoadLatency())
+
+	for _, id := range []int64{1, 1, 2, 2, 2} {
+		_, err := testInstance.GetCreature(ctx, id)
+		require.NoError(t, err)
+	}
+	_, err := testInstance.GetCreature(ctx, 3)
+	require.Error(t, err)
+	_, err = testInstance.GetCreatures(ctx, []int64{1, 2, 4})
+	require.NoError(t, err)
+	_, err = testInstance.GetCreatureByName(ctx, "bob")
+	require.NoError(t, err)
+
+	stats := testInstance.Stats()
+	assert.Equal(t, 3, stats.Size)
+	// 1 and 2 twice each from GetCreature, both again from GetCreatures, and bob by name
+	assert.Equal(t, uint64(6), stats.Hits)
+	assert.Equal(t, uint64(3), stats.NegativeHits)
+	assert.Equal(t, uint64(4), stats.Misses)
+	assert.Equal(t, uint64(4), stats.Loads)
+	assert.Equal(t, uint64(1), stats.LoadErrors)
+	assert.Equal(t, 100*time.Millisecond, stats.LoadTime)
+	assert.Equal(t, 25*time.Millisecond, stats.AverageLoadLatency())
+	assert.Equal(t, 0.6, stats.HitRatio())
+}
+
+func TestCachingCreatureRepo_WarmUp(t *testing.T) {
+	ctx := context.Background()
+
+	lookupResult := func(id int64) CreatureLookupResult {
+		return CreatureLookupResult{
+			ResultFound: true,
+			Creature:    Creature{ID: id, Name: fmt.Sprintf("creature_%d", id)},
+		}
+	}
+	rawRepo := NewMockRawCreatureRepo(t)
+	testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(0)).Return(lookupResult(0), nil).Once()
+	_, err := testInstance.GetCreature(ctx, 0)
+	require.NoError(t, err)
+
+	// enough ids to need a few batches, with duplicates and an already cached id that shouldn't be asked for
+	var ids []int64
+	for id := int64(0); id < 2*warmUpBatchSize+10; id++ {
+		ids = append(ids, id, id)
+	}
+	var requested []int64
+	rawRepo.EXPECT().GetCreatures(mock.Anything, mock.Anything).RunAndReturn(func(ctx context.Context, ids []int64) (map[int64]CreatureLookupResult, error) {
+		assert.LessOrEqual(t, len(ids), warmUpBatchSize)
+		requested = append(requested, ids...)
+		ret := make(map[int64]CreatureLookupResult, len(ids))
+		for _, id := range ids {
+			ret[id] = lookupResult(id)
+		}
+		return ret, nil
+	}).Times(3)
+
+	require.NoError(t, testInstance.WarmUp(ctx, ids))
+	assert.Len(t, requested, 2*warmUpBatchSize+9)
+	assert.NotContains(t, requested, int64(0))
+
+	stats := testInstance.Stats()
+	assert.Equal(t, 2*warmUpBatchSize+10, stats.Size)
+	assert.Equal(t, uint64(0), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+
+	// everything should now be served from cache
+	for id := int64(0); id < 2*warmUpBatchSize+10; id++ {
+		result, err := testInstance.GetCreature(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, lookupResult(id), result)
+	}
+}
+
+func TestCachingCreatureRepo_WarmUp_Error(t *testing.T) {
+	ctx := context.Background()
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreatures(mock.Anything, []int64{1, 2}).Return(nil, errors.New("some DB error here")).Once()
+	testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+	err := testInstance.WarmUp(ctx, []int64{1, 2})
+	assert.Equal(t, errors.New("some DB error here"), err)
+	assert.Equal(t, 0, testInstance.Stats().Size)
+}
+
+func TestCachingCreatureRepo_WarmUpAll(t *testing.T) {
+	ctx := context.Background()
+
+	bob := Creature{ID: 1, Name: "bob", Description: "likes testing"}
+	alice := Creature{ID: 2, Name: "alice", Description: "likes reviewing"}
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().ListCreatures(mock.Anything, ListOptions{PageSize: warmUpBatchSize, OrderBy: OrderByID}).Return(CreaturePage{
+		Creatures:  []Creature{bob},
+		NextCursor: "next",
+	}, nil).Once()
+	rawRepo.EXPECT().ListCreatures(mock.Anything, ListOptions{PageSize: warmUpBatchSize, OrderBy: OrderByID, Cursor: "next"}).Return(CreaturePage{
+		Creatures: []Creature{alice},
+	}, nil).Once()
+	testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+	require.NoError(t, testInstance.WarmUpAll(ctx))
+	for _, creature := range []Creature{bob, alice} {
+		result, err := testInstance.GetCreature(ctx, creature.ID)
+		require.NoError(t, err)
+		assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: creature}, result)
+		result, err = testInstance.GetCreatureByName(ctx, creature.Name)
+		require.NoError(t, err)
+		assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: creature}, result)
+	}
+
+	// a failure part way through is handed back
+	rawRepo.EXPECT().ListCreatures(mock.Anything, mock.Anything).Return(CreaturePage{}, errors.New("some DB error here")).Once()
+	assert.Equal(t, errors.New("some DB error here"), testInstance.WarmUpAll(ctx))
+}
+
+func TestCachingCreatureRepo_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 3, 14, 15, 9, 26, 0, time.UTC)
+	negativeCacheDuration := time.Minute
+
+	bob := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+	alice := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 2, Name: "alice", Description: "likes reviewing"},
+	}
+	notFound := CreatureLookupResult{ResultFound: false}
+
+	testCases := []struct {
+		name string
+		// downtime is how long passes between the first repo closing and the second starting up
+		downtime        time.Duration
+		expectedEntries map[int64]CreatureCacheEntry
+	}{
+		{
+			name:     "quick restart",
+			downtime: time.Second,
+			expectedEntries: map[int64]CreatureCacheEntry{
+				1: {Result: bob, CachedAt: start, ExpiresAt: start.Add(time.Hour)},
+				2: {Result: alice, CachedAt: start.Add(30 * time.Minute), ExpiresAt: start.Add(90 * time.Minute)},
+				3: {Result: notFound, CachedAt: start.Add(30 * time.Minute), ExpiresAt: start.Add(31 * time.Minute)},
+			},
+		},
+		{
+			name:     "outlived some entries",
+			downtime: 31 * time.Minute,
+			expectedEntries: map[int64]CreatureCacheEntry{
+				// bob has expired, but is still within his stale window
+				1: {Result: bob, CachedAt: start, ExpiresAt: start.Add(time.Hour)},
+				2: {Result: alice, CachedAt: start.Add(30 * time.Minute), ExpiresAt: start.Add(90 * time.Minute)},
+			},
+		},
+		{
+			name:            "outlived everything",
+			downtime:        2 * time.Hour,
+			expectedEntries: map[int64]CreatureCacheEntry{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := srptest.NewManualClock(start)
+			options := CachingCreatureRepoOptions{
+				CacheDuration:         time.Hour,
+				NegativeCacheDuration: &negativeCacheDuration,
+				StaleWindow:           5 * time.Minute,
+				Clock:                 clock,
+				SnapshotPath:          filepath.Join(t.TempDir(), "creatures.json"),
+			}
+
+			rawRepo := NewMockRawCreatureRepo(t)
+			rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(bob, nil).Once()
+			rawRepo.EXPECT().GetCreature(mock.Anything, int64(2)).Return(alice, nil).Once()
+			rawRepo.EXPECT().GetCreature(mock.Anything, int64(3)).Return(notFound, nil).Once()
+			first := NewCachingCreatureRepoWithOptions(rawRepo, options)
+			_, err := first.GetCreature(ctx, 1)
+			require.NoError(t, err)
+			clock.Advance(30 * time.Minute)
+			for _, id := range []int64{2, 3} {
+				_, err = first.GetCreature(ctx, id)
+				require.NoError(t, err)
+			}
+			require.NoError(t, first.Close())
+
+			clock.Advance(tc.downtime)
+			// the restored repo must manage without ever going to the raw repo
+			testInstance := NewCachingCreatureRepoWithOptions(NewMockRawCreatureRepo(t), options)
+			defer testInstance.Close()
+			assert.Equal(t, len(tc.expectedEntries), testInstance.Stats().Size)
+			for id, expected := range tc.expectedEntries {
+				entry, cached := testInstance.Peek(ctx, id)
+				assert.True(t, cached, "id %d", id)
+				assert.Equal(t, expected.Result, entry.Result, "id %d", id)
+				assert.True(t, expected.CachedAt.Equal(entry.CachedAt), "id %d", id)
+				assert.True(t, expected.ExpiresAt.Equal(entry.ExpiresAt), "id %d", id)
+			}
+			if _, restored := tc.expectedEntries[2]; restored {
+				result, err := testInstance.GetCreatureByName(ctx, "alice")
+				require.NoError(t, err)
+				assert.Equal(t, alice, result)
+			}
+		})
+	}
+}
+
+func TestCachingCreatureRepo_Snapshot_CacheDurationsShortened(t *testing.T) {
+	ctx := context.Background()
+	clock := srptest.NewManualClock(time.Date(2024, 3, 14, 15, 9, 26, 0, time.UTC))
+	snapshotPath := filepath.Join(t.TempDir(), "creatures.json")
+
+	bob := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(bob, nil).Once()
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(2)).Return(CreatureLookupResult{ResultFound: false}, nil).Once()
+	first := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration: time.Hour,
+		Clock:         clock,
+		SnapshotPath:  snapshotPath,
+	})
+	for _, id := range []int64{1, 2} {
+		_, err := first.GetCreature(ctx, id)
+		require.NoError(t, err)
+	}
+	require.NoError(t, first.Close())
+
+	// restored entries shouldn't live longer than the repo would now cache them for, or be cached at all if it no
+	// longer would
+	clock.Advance(30 * time.Second)
+	noNegativeCaching := time.Duration(0)
+	testInstance := NewCachingCreatureRepoWithOptions(NewMockRawCreatureRepo(t), CachingCreatureRepoOptions{
+		CacheDuration:         time.Minute,
+		NegativeCacheDuration: &noNegativeCaching,
+		Clock:                 clock,
+		SnapshotPath:          snapshotPath,
+	})
+	entry, cached := testInstance.Peek(ctx, 1)
+	require.True(t, cached)
+	assert.True(t, entry.CachedAt.Add(time.Minute).Equal(entry.ExpiresAt))
+	_, cached = testInstance.Peek(ctx, 2)
+	assert.False(t, cached)
+}
+
+func TestCachingCreatureRepo_Snapshot_Unusable(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name        string
+		snapshot    string
+		expectedLog string
+	}{
+		{
+			name:        "corrupted",
+			snapshot:    `{"Version":1,"Entries":{"1":{"Result":`,
+			expectedLog: "error restoring cache snapshot",
+		},
+		{
+			name:        "not json at all",
+			snapshot:    "bob likes testing",
+			expectedLog: "error restoring cache snapshot",
+		},
+		{
+			name:        "wrong version",
+			snapshot:    `{"Version":99,"Entries":{}}`,
+			expectedLog: "snapshot is version 99",
+		},
+		{
+			name: "missing",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			snapshotPath := filepath.Join(t.TempDir(), "creatures.json")
+			if tc.snapshot != "" {
+				require.NoError(t, os.WriteFile(snapshotPath, []byte(tc.snapshot), 0o600))
+			}
+			logs := &bytes.Buffer{}
+
+			bob := CreatureLookupResult{
+				ResultFound: true,
+				Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+			}
+			rawRepo := NewMockRawCreatureRepo(t)
+			rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(bob, nil).Once()
+
+			// an unusable snapshot leaves the repo to start cold
+			testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+				CacheDuration: time.Hour,
+				Logger:        slog.New(slog.NewTextHandler(logs, nil)),
+				SnapshotPath:  snapshotPath,
+			})
+			assert.Equal(t, 0, testInstance.Stats().Size)
+			if tc.expectedLog != "" {
+				assert.Contains(t, logs.String(), tc.expectedLog)
+			} else {
+				assert.Empty(t, logs.String())
+			}
+			result, err := testInstance.GetCreature(ctx, 1)
+			require.NoError(t, err)
+			assert.Equal(t, bob, result)
+
+			// and is replaced by a good one on close
+			require.NoError(t, testInstance.Close())
+			restored := NewCachingCreatureRepoWithOptions(NewMockRawCreatureRepo(t), CachingCreatureRepoOptions{
+				CacheDuration: time.Hour,
+				SnapshotPath:  snapshotPath,
+			})
+			result, err = restored.GetCreature(ctx, 1)
+			require.NoError(t, err)
+			assert.Equal(t, bob, result)
+		})
+	}
+}
+
+func TestCachingCreatureRepo_Snapshot_UnlistableCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	testInstance := NewCachingCreatureRepoWithOptions(NewMockRawCreatureRepo(t), CachingCreatureRepoOptions{
+		CacheDuration: time.Hour,
+		Cache:         NewRedisCreatureCache(client, ""),
+		SnapshotPath:  filepath.Join(t.TempDir(), "creatures.json"),
+	})
+	assert.Error(t, testInstance.Close())
+}
+
+func TestCachingCreatureRepo_WritePolicy(t *testing.T) {
+	ctx := context.Background()
+	newName := "robert"
+
+	bob := Creature{ID: 1, Name: "bob", Description: "likes testing"}
+	robert := Creature{ID: 1, Name: "robert", Description: "likes testing"}
+
+	testCases := []struct {
+		name        string
+		writePolicy WritePolicy
+		// expectCached is whether the outcome of each write is expected to be cached, rather than the entry dropped
+		expectCached bool
+	}{
+		{
+			name:         "write through",
+			writePolicy:  WriteThrough,
+			expectCached: true,
+		},
+		{
+			name:         "write around",
+			writePolicy:  WriteAround,
+			expectCached: false,
+		},
+		{
+			// updates and deletes are always written through when writing behind
+			name:         "write behind",
+			writePolicy:  WriteBehind,
+			expectCached: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rawRepo := NewMockRawCreatureRepo(t)
+			testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+				CacheDuration: time.Hour,
+				WritePolicy:   tc.writePolicy,
+			})
+			defer testInstance.Close()
+
+			// every write starts out with an outdated entry in the cache
+			primeCache := func() {
+				rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(CreatureLookupResult{ResultFound: false}, nil).Once()
+				testInstance.Invalidate(ctx, 1)
+				_, err := testInstance.GetCreature(ctx, 1)
+				require.NoError(t, err)
+			}
+			checkCache := func(expected CreatureLookupResult) {
+				entry, cached := testInstance.Peek(ctx, 1)
+				assert.Equal(t, tc.expectCached, cached)
+				if tc.expectCached {
+					assert.Equal(t, expected, entry.Result)
+				}
+			}
+
+			if tc.writePolicy != WriteBehind {
+				primeCache()
+				rawRepo.EXPECT().CreateCreature(mock.Anything, "bob", "likes testing").Return(bob, nil).Once()
+				_, err := testInstance.CreateCreature(ctx, "bob", "likes testing")
+				require.NoError(t, err)
+				checkCache(CreatureLookupResult{ResultFound: true, Creature: bob})
+			}
+
+			primeCache()
+			rawRepo.EXPECT().UpdateCreature(mock.Anything, int64(1), CreatureUpdate{Name: &newName}).Return(CreatureUpdateResult{ResultFound: true, Creature: robert}, nil).Once()
+			_, err := testInstance.UpdateCreature(ctx, 1, CreatureUpdate{Name: &newName})
+			require.NoError(t, err)
+			checkCache(CreatureLookupResult{ResultFound: true, Creature: robert})
+
+			rawRepo.EXPECT().DeleteCreature(mock.Anything, int64(1)).Return(CreatureDeleteResult{ResultFound: true}, nil).Once()
+			_, err = testInstance.DeleteCreature(ctx, 1)
+			require.NoError(t, err)
+			checkCache(CreatureLookupResult{ResultFound: false})
+
+			// whatever the policy, failed writes leave nothing behind
+			primeCache()
+			rawRepo.EXPECT().UpdateCreature(mock.Anything, int64(1), CreatureUpdate{Name: &newName}).Return(CreatureUpdateResult{}, errors.New("some DB error here")).Once()
+			_, err = testInstance.UpdateCreature(ctx, 1, CreatureUpdate{Name: &newName})
+			assert.Equal(t, errors.New("some DB error here"), err)
+			_, cached := testInstance.Peek(ctx, 1)
+			assert.False(t, cached)
+		})
+	}
+}
+
+func TestCachingCreatureRepo_CreateCreatures(t *testing.T) {
+	ctx := context.Background()
+
+	toCreate := []Creature{{Name: "bob", Description: "likes testing"}, {Name: "alice", Description: "likes reviewing"}}
+	created := []Creature{{ID: 1, Name: "bob", Description: "likes testing"}, {ID: 2, Name: "alice", Description: "likes reviewing"}}
+
+	testCases := []struct {
+		name         string
+		writePolicy  WritePolicy
+		expectCached bool
+	}{
+		{
+			name:         "write through",
+			writePolicy:  WriteThrough,
+			expectCached: true,
+		},
+		{
+			name:         "write around",
+			writePolicy:  WriteAround,
+			expectCached: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rawRepo := NewMockRawCreatureRepo(t)
+			rawRepo.EXPECT().CreateCreatures(mock.Anything, toCreate).Return(created, nil).Once()
+			testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+				CacheDuration: time.Hour,
+				WritePolicy:   tc.writePolicy,
+			})
+			defer testInstance.Close()
+
+			res, err := testInstance.CreateCreatures(ctx, toCreate)
+			require.NoError(t, err)
+			assert.Equal(t, created, res)
+			for _, creature := range created {
+				entry, cached := testInstance.Peek(ctx, creature.ID)
+				assert.Equal(t, tc.expectCached, cached)
+				if tc.expectCached {
+					assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: creature}, entry.Result)
+				}
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		rawRepo := NewMockRawCreatureRepo(t)
+		rawRepo.EXPECT().CreateCreatures(mock.Anything, toCreate).Return(nil, ErrDuplicateName).Once()
+		testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+		_, err := testInstance.CreateCreatures(ctx, toCreate)
+		assert.ErrorIs(t, err, ErrDuplicateName)
+		assert.Equal(t, 0, testInstance.Stats().Size)
+	})
+
+	t.Run("write behind", func(t *testing.T) {
+		rawRepo := NewMockRawCreatureRepo(t)
+		rawRepo.EXPECT().CreateCreatures(mock.Anything, toCreate).Return(created, nil).Once()
+		testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+			CacheDuration:            time.Hour,
+			WritePolicy:              WriteBehind,
+			WriteBehindFlushInterval: time.Hour,
+		})
+
+		res, err := testInstance.CreateCreatures(ctx, toCreate)
+		require.NoError(t, err)
+		assert.Equal(t, toCreate, res)
+		assert.Equal(t, 2, testInstance.Stats().PendingWrites)
+		require.NoError(t, testInstance.Close())
+		for _, creature := range created {
+			_, cached := testInstance.Peek(ctx, creature.ID)
+			assert.True(t, cached)
+		}
+	})
+}
+
+func TestCachingCreatureRepo_WriteBehind(t *testing.T) {
+	ctx := context.Background()
+
+	var made []string
+	var batchSizes []int
+	madeMutex := sync.Mutex{}
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().CreateCreatures(mock.Anything, mock.Anything).RunAndReturn(func(ctx context.Context, creatures []Creature) ([]Creature, error) {
+		madeMutex.Lock()
+		defer madeMutex.Unlock()
+		batchSizes = append(batchSizes, len(creatures))
+		var ret []Creature
+		for _, creature := range creatures {
+			made = append(made, creature.Name)
+			ret = append(ret, Creature{ID: int64(len(made)), Name: creature.Name, Description: creature.Description})
+		}
+		return ret, nil
+	}).Times(3)
+
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration:            time.Hour,
+		WritePolicy:              WriteBehind,
+		WriteBehindBatchSize:     2,
+		WriteBehindFlushInterval: time.Hour,
+	})
+
+	var names []string
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("creature_%d", i)
+		names = append(names, name)
+		res, err := testInstance.CreateCreature(ctx, name, "likes testing")
+		require.NoError(t, err)
+		// there is no way of knowing the id until the create has been made
+		assert.Equal(t, Creature{Name: name, Description: "likes testing"}, res)
+	}
+
+	// the last create is waiting on a batch to fill up, closing must see it made regardless
+	require.NoError(t, testInstance.Close())
+	assert.Equal(t, names, made)
+	assert.Equal(t, []int{2, 2, 1}, batchSizes)
+	assert.Equal(t, 0, testInstance.Stats().PendingWrites)
+	for i, name := range names {
+		result, err := testInstance.GetCreatureByName(ctx, name)
+		require.NoError(t, err)
+		assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: Creature{ID: int64(i + 1), Name: name, Description: "likes testing"}}, result)
+	}
+
+	// once closed there is nothing left to write behind, so creates are written through
+	rawRepo.EXPECT().CreateCreature(mock.Anything, "bob", "likes testing").Return(Creature{ID: 1234, Name: "bob", Description: "likes testing"}, nil).Once()
+	res, err := testInstance.CreateCreature(ctx, "bob", "likes testing")
+	require.NoError(t, err)
+	assert.Equal(t, Creature{ID: 1234, Name: "bob", Description: "likes testing"}, res)
+}
+
+func TestCachingCreatureRepo_WriteBehind_Flush(t *testing.T) {
+	ctx := context.Background()
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().CreateCreatures(mock.Anything, []Creature{{Name: "bob", Description: "likes testing"}}).Return([]Creature{{ID: 1, Name: "bob", Description: "likes testing"}}, nil).Once()
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration:            time.Hour,
+		WritePolicy:              WriteBehind,
+		WriteBehindFlushInterval: time.Hour,
+	})
+	defer testInstance.Close()
+
+	_, err := testInstance.CreateCreature(ctx, "bob", "likes testing")
+	require.NoError(t, err)
+	assert.Equal(t, 1, testInstance.Stats().PendingWrites)
+
+	require.NoError(t, testInstance.Flush(ctx))
+	assert.Equal(t, 0, testInstance.Stats().PendingWrites)
+	entry, cached := testInstance.Peek(ctx, 1)
+	assert.True(t, cached)
+	assert.Equal(t, "bob", entry.Result.Creature.Name)
+
+	// flushing with nothing queued is fine, as is flushing when not writing behind at all
+	require.NoError(t, testInstance.Flush(ctx))
+	require.NoError(t, NewCachingCreatureRepo(rawRepo, time.Hour).Flush(ctx))
+}
+
+func TestCachingCreatureRepo_WriteBehind_FlushInterval(t *testing.T) {
+	ctx := context.Background()
+	clock := srptest.NewManualClock(time.Now())
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().CreateCreatures(mock.Anything, []Creature{{Name: "bob", Description: "likes testing"}}).Return([]Creature{{ID: 1, Name: "bob", Description: "likes testing"}}, nil).Once()
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration:            time.Hour,
+		WritePolicy:              WriteBehind,
+		WriteBehindFlushInterval: time.Second,
+		Clock:                    clock,
+	})
+	defer testInstance.Close()
+
+	_, err := testInstance.CreateCreature(ctx, "bob", "likes testing")
+	require.NoError(t, err)
+	// wait for bob to be taken off the queue, as a flush only sees creates that have been
+	assert.Eventually(t, func() bool {
+		return len(testInstance.writeBehind.pending) == 0
+	}, time.Second, time.Millisecond)
+
+	// not yet time to flush
+	clock.Advance(time.Second - time.Nanosecond)
+	assert.Equal(t, 1, testInstance.Stats().PendingWrites)
+
+	// the queue only takes a tick once done with the one before, so a second tick sees the first one's flush through
+	clock.Advance(time.Nanosecond)
+	clock.Advance(time.Second)
+	assert.Equal(t, 0, testInstance.Stats().PendingWrites)
+	_, cached := testInstance.Peek(ctx, 1)
+	assert.True(t, cached)
+}
+
+func TestCachingCreatureRepo_WriteBehind_Error(t *testing.T) {
+	ctx := context.Background()
+	logs := &bytes.Buffer{}
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	// bob's name being in use sinks the whole batch, which is then made one create at a time
+	rawRepo.EXPECT().CreateCreatures(mock.Anything, []Creature{{Name: "bob", Description: "likes testing"}, {Name: "alice", Description: "likes reviewing"}}).Return(nil, ErrDuplicateName).Once()
+	rawRepo.EXPECT().CreateCreature(mock.Anything, "bob", "likes testing").Return(Creature{}, ErrDuplicateName).Once()
+	rawRepo.EXPECT().CreateCreature(mock.Anything, "alice", "likes reviewing").Return(Creature{ID: 2, Name: "alice", Description: "likes reviewing"}, nil).Once()
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration: time.Hour,
+		WritePolicy:   WriteBehind,
+		Logger:        slog.New(slog.NewTextHandler(logs, nil)),
+	})
+
+	// the failure only comes to light once the create is made, by which point it has long been acknowledged
+	_, err := testInstance.CreateCreature(ctx, "bob", "likes testing")
+	require.NoError(t, err)
+	_, err = testInstance.CreateCreature(ctx, "alice", "likes reviewing")
+	require.NoError(t, err)
+	require.NoError(t, testInstance.Close())
+
+	assert.Equal(t, uint64(1), testInstance.Stats().WriteErrors)
+	assert.Contains(t, logs.String(), "error writing queued creature")
+	_, cached := testInstance.Peek(ctx, 2)
+	assert.True(t, cached)
+}
+
+func TestCachingCreatureRepo_WriteBehind_QueueFull(t *testing.T) {
+	ctx := context.Background()
+
+	writing := make(chan struct{})
+	release := make(chan struct{})
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().CreateCreatures(mock.Anything, []Creature{{Name: "bob", Description: "likes testing"}}).RunAndReturn(func(ctx context.Context, creatures []Creature) ([]Creature, error) {
+		close(writing)
+		<-release
+		return []Creature{{ID: 1, Name: "bob", Description: "likes testing"}}, nil
+	}).Once()
+	rawRepo.EXPECT().CreateCreatures(mock.Anything, []Creature{{Name: "alice", Description: "likes reviewing"}}).Return([]Creature{{ID: 2, Name: "alice", Description: "likes reviewing"}}, nil).Once()
+	testInstance := NewCachingCreatureRepoWithOptions(rawRepo, CachingCreatureRepoOptions{
+		CacheDuration:        time.Hour,
+		WritePolicy:          WriteBehind,
+		WriteBehindQueueSize: 1,
+		WriteBehindBatchSize: 1,
+	})
+
+	// bob is taken off the queue and held up being written, leaving room for alice and no one else
+	_, err := testInstance.CreateCreature(ctx, "bob", "likes testing")
+	require.NoError(t, err)
+	<-writing
+	_, err = testInstance.CreateCreature(ctx, "alice", "likes reviewing")
+	require.NoError(t, err)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = testInstance.CreateCreature(timeoutCtx, "carol", "likes waiting")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 2, testInstance.Stats().PendingWrites)
+
+	close(release)
+	require.NoError(t, testInstance.Close())
+	assert.Equal(t, 0, testInstance.Stats().PendingWrites)
+}
+
+// BenchmarkCachingCreatureRepo_GetCreature_Parallel compares a single shard against a sharded repo across a range of
+// GOMAXPROCS and hit ratios. Misses are served without any latency so that the cost of locking isn't hidden behind it.
+func BenchmarkCachingCreatureRepo_GetCreature_Parallel(b *testing.B) {
+	for _, procs := range []int{1, 4, 16} {
+		for _, hitRatio := range []float64{0.5, 0.9, 1} {
+			for _, shards := range []int{1, 16} {
+				b.Run(fmt.Sprintf("procs %d hit ratio %.2f shards %d", procs, hitRatio, shards), func(b *testing.B) {
+					defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+					ctx := context.Background()
+					testInstance := NewCachingCreatureRepoWithOptions(&slowRawCreatureRepo{}, CachingCreatureRepoOptions{
+						CacheDuration: time.Hour,
+						Shards:        shards,
+					})
+
+					cachedIDs := int64(1000)
+					for id := int64(0); id < cachedIDs; id++ {
+						_, err := testInstance.GetCreature(ctx, id)
+						require.NoError(b, err)
+					}
+					// misses always ask for an id nobody has asked for before
+					var nextMiss atomic.Int64
+					nextMiss.Store(cachedIDs)
+
+					b.ResetTimer()
+					b.RunParallel(func(pb *testing.PB) {
+						rnd := rand.New(rand.NewSource(rand.Int63()))
+						for pb.Next() {
+							id := rnd.Int63n(cachedIDs)
+							if rnd.Float64() >= hitRatio {
+								id = nextMiss.Add(1)
+							}
+							_, err := testInstance.GetCreature(ctx, id)
+							if err != nil {
+								b.Fatal(err)
+							}
+						}
+					})
+				})
+			}
+		}
+	}
+}
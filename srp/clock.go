@@ -0,0 +1,32 @@
+package srp
+
+import "time"
+
+// Clock is the source of time for any decisions made based on it, such as cache expiry
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a Clock backed by the system clock
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// TickerClock is implemented by clocks that decide for themselves when time passes, letting them drive background work
+// done on an interval, such as sweeping expired entries, as well. NewTicker returns a channel delivering ticks every d,
+// along with a function stopping them.
+type TickerClock interface {
+	Clock
+	NewTicker(d time.Duration) (<-chan time.Time, func())
+}
+
+// newTicker ticks every d according to clock if it is a TickerClock, or according to the system clock otherwise
+func newTicker(clock Clock, d time.Duration) (<-chan time.Time, func()) {
+	if tickerClock, ticks := clock.(TickerClock); ticks {
+		return tickerClock.NewTicker(d)
+	}
+	ticker := time.NewTicker(d)
+	return ticker.C, ticker.Stop
+}
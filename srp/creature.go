@@ -10,3 +10,39 @@ type CreatureLookupResult struct {
 	ResultFound bool
 	Creature    Creature
 }
+
+// CreatureUpdate describes the changes to apply to a creature, nil fields are left untouched
+type CreatureUpdate struct {
+	Name        *string
+	Description *string
+}
+
+type CreatureUpdateResult struct {
+	ResultFound bool
+	Creature    Creature
+}
+
+type CreatureDeleteResult struct {
+	ResultFound bool
+}
+
+type ListOrder int
+
+const (
+	OrderByID ListOrder = iota
+	OrderByName
+)
+
+// ListOptions controls a ListCreatures call. A zero PageSize uses the repo's default page size, and Cursor should be
+// empty for the first page and the NextCursor of the previous page thereafter.
+type ListOptions struct {
+	PageSize int
+	OrderBy  ListOrder
+	Cursor   string
+}
+
+// CreaturePage is a single page of a listing, NextCursor is empty once there are no further pages
+type CreaturePage struct {
+	Creatures  []Creature
+	NextCursor string
+}
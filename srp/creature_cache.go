@@ -0,0 +1,36 @@
+package srp
+
+import (
+	"context"
+	"time"
+)
+
+// CreatureCacheEntry is a cached lookup result along with what CachingCreatureRepo needs to know to decide how fresh it
+// is
+type CreatureCacheEntry struct {
+	Result    CreatureLookupResult
+	CachedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// CreatureCache is the storage behind a CachingCreatureRepo. Implementations are free to drop entries whenever they see
+// fit, but must not return an entry once the ttl it was set with has passed. Multiple repos may share a cache, in which
+// case they should be configured identically.
+type CreatureCache interface {
+	Get(ctx context.Context, id int64) (CreatureCacheEntry, bool, error)
+	Set(ctx context.Context, id int64, entry CreatureCacheEntry, ttl time.Duration) error
+	Delete(ctx context.Context, id int64) error
+	Clear(ctx context.Context) error
+}
+
+// cacheStatsReporter is implemented by caches that keep track of their own housekeeping, which CachingCreatureRepo
+// folds into its stats
+type cacheStatsReporter interface {
+	Stats() CacheStats
+}
+
+// cacheEntryLister is implemented by caches able to hand over everything they hold, which CachingCreatureRepo needs to
+// save snapshots
+type cacheEntryLister interface {
+	Entries() map[int64]CreatureCacheEntry
+}
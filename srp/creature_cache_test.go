@@ -0,0 +1,175 @@
+package srp
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jonsabados/srp-sample/srp/srptest"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// creatureCacheUnderTest is a cache along with a means of moving its notion of time forward
+type creatureCacheUnderTest struct {
+	cache   CreatureCache
+	advance func(d time.Duration)
+}
+
+func TestCreatureCache(t *testing.T) {
+	testCases := []struct {
+		name     string
+		newCache func(t *testing.T) creatureCacheUnderTest
+	}{
+		{
+			name: "memory",
+			newCache: func(t *testing.T) creatureCacheUnderTest {
+				clock := srptest.NewManualClock(time.Now())
+				return creatureCacheUnderTest{
+					cache:   NewMemoryCreatureCache(MemoryCreatureCacheOptions{Clock: clock}),
+					advance: clock.Advance,
+				}
+			},
+		},
+		{
+			name: "sharded memory",
+			newCache: func(t *testing.T) creatureCacheUnderTest {
+				clock := srptest.NewManualClock(time.Now())
+				return creatureCacheUnderTest{
+					cache:   NewShardedMemoryCreatureCache(4, MemoryCreatureCacheOptions{Clock: clock}),
+					advance: clock.Advance,
+				}
+			},
+		},
+		{
+			name: "redis",
+			newCache: func(t *testing.T) creatureCacheUnderTest {
+				server := miniredis.RunT(t)
+				client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+				t.Cleanup(func() {
+					_ = client.Close()
+				})
+				return creatureCacheUnderTest{
+					cache:   NewRedisCreatureCache(client, ""),
+					advance: server.FastForward,
+				}
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			underTest := tc.newCache(t)
+			testInstance := underTest.cache
+
+			cachedAt := time.Date(2024, 3, 14, 15, 9, 26, 0, time.UTC)
+			entry := func(id int64) CreatureCacheEntry {
+				return CreatureCacheEntry{
+					Result: CreatureLookupResult{
+						ResultFound: true,
+						Creature:    Creature{ID: id, Name: fmt.Sprintf("creature_%d", id), Description: "likes testing"},
+					},
+					CachedAt:  cachedAt,
+					ExpiresAt: cachedAt.Add(time.Minute),
+				}
+			}
+			notFound := CreatureCacheEntry{
+				Result:    CreatureLookupResult{ResultFound: false},
+				CachedAt:  cachedAt,
+				ExpiresAt: cachedAt.Add(time.Minute),
+			}
+
+			_, found, err := testInstance.Get(ctx, 1)
+			require.NoError(t, err)
+			assert.False(t, found)
+
+			require.NoError(t, testInstance.Set(ctx, 1, entry(1), time.Minute))
+			require.NoError(t, testInstance.Set(ctx, 2, entry(2), 2*time.Minute))
+			require.NoError(t, testInstance.Set(ctx, 3, notFound, 2*time.Minute))
+			for id, expected := range map[int64]CreatureCacheEntry{1: entry(1), 2: entry(2), 3: notFound} {
+				got, found, err := testInstance.Get(ctx, id)
+				require.NoError(t, err)
+				assert.True(t, found, "id %d", id)
+				assert.Equal(t, expected, got, "id %d", id)
+			}
+
+			// entries must not be handed back once their ttl has passed
+			underTest.advance(time.Minute + time.Second)
+			_, found, err = testInstance.Get(ctx, 1)
+			require.NoError(t, err)
+			assert.False(t, found)
+			_, found, err = testInstance.Get(ctx, 2)
+			require.NoError(t, err)
+			assert.True(t, found)
+
+			require.NoError(t, testInstance.Delete(ctx, 2))
+			_, found, err = testInstance.Get(ctx, 2)
+			require.NoError(t, err)
+			assert.False(t, found)
+			// deleting what isn't there is fine
+			require.NoError(t, testInstance.Delete(ctx, 2))
+
+			for id := int64(10); id < 20; id++ {
+				require.NoError(t, testInstance.Set(ctx, id, entry(id), time.Minute))
+			}
+			require.NoError(t, testInstance.Clear(ctx))
+			for id := int64(1); id < 20; id++ {
+				_, found, err = testInstance.Get(ctx, id)
+				require.NoError(t, err)
+				assert.False(t, found, "id %d", id)
+			}
+		})
+	}
+}
+
+func TestRedisCreatureCache_KeyPrefix(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	entry := CreatureCacheEntry{
+		Result: CreatureLookupResult{
+			ResultFound: true,
+			Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+		},
+		CachedAt:  time.Date(2024, 3, 14, 15, 9, 26, 0, time.UTC),
+		ExpiresAt: time.Date(2024, 3, 14, 15, 10, 26, 0, time.UTC),
+	}
+
+	first := NewRedisCreatureCache(client, "first:")
+	second := NewRedisCreatureCache(client, "second:")
+	require.NoError(t, first.Set(ctx, 1, entry, time.Minute))
+	require.NoError(t, second.Set(ctx, 1, entry, time.Minute))
+	require.NoError(t, client.Set(ctx, "unrelated", "value", 0).Err())
+
+	assert.True(t, server.Exists("first:1"))
+	assert.True(t, server.Exists("second:1"))
+
+	// clearing one cache must leave the other, and anything else in redis, alone
+	require.NoError(t, first.Clear(ctx))
+	assert.False(t, server.Exists("first:1"))
+	assert.True(t, server.Exists("second:1"))
+	assert.True(t, server.Exists("unrelated"))
+
+	// a ttl that has already run out shouldn't leave an entry that never expires
+	require.NoError(t, second.Set(ctx, 1, entry, 0))
+	assert.False(t, server.Exists("second:1"))
+}
+
+func TestRedisCreatureCache_CorruptEntry(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	require.NoError(t, server.Set(DefaultRedisKeyPrefix+"1", "not json"))
+
+	testInstance := NewRedisCreatureCache(client, "")
+	_, found, err := testInstance.Get(ctx, 1)
+	assert.Error(t, err)
+	assert.False(t, found)
+}
@@ -4,39 +4,75 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
 )
 
 type ConnectionOpener interface {
 	OpenConnection() (*sql.DB, error)
 }
 
+// ReadConnectionOpener is implemented by connection openers able to send reads somewhere other than where writes go, such
+// as a read replica. CreatureRepo makes its reads against OpenReadConnection when its opener implements it.
+type ReadConnectionOpener interface {
+	ConnectionOpener
+	OpenReadConnection(ctx context.Context) (*sql.DB, error)
+}
+
+const (
+	DefaultListPageSize    = 50
+	DefaultMaxListPageSize = 500
+)
+
+// CreatureRepoOptions allows tuning of a CreatureRepo, zero values fall back to the package defaults
+type CreatureRepoOptions struct {
+	DefaultPageSize int
+	MaxPageSize     int
+}
+
 type CreatureRepo struct {
 	connectionOpener ConnectionOpener
+	defaultPageSize  int
+	maxPageSize      int
 }
 
 func NewCreatureRepo(connectionOpener ConnectionOpener) *CreatureRepo {
+	return NewCreatureRepoWithOptions(connectionOpener, CreatureRepoOptions{})
+}
+
+func NewCreatureRepoWithOptions(connectionOpener ConnectionOpener, options CreatureRepoOptions) *CreatureRepo {
+	maxPageSize := options.MaxPageSize
+	if maxPageSize <= 0 {
+		maxPageSize = DefaultMaxListPageSize
+	}
+	defaultPageSize := options.DefaultPageSize
+	if defaultPageSize <= 0 {
+		defaultPageSize = DefaultListPageSize
+	}
 	return &CreatureRepo{
 		connectionOpener: connectionOpener,
+		defaultPageSize:  min(defaultPageSize, maxPageSize),
+		maxPageSize:      maxPageSize,
 	}
 }
 
 func (c *CreatureRepo) CreateCreature(ctx context.Context, name, description string) (Creature, error) {
 	db, err := c.connectionOpener.OpenConnection()
 	if err != nil {
-		return Creature{}, err
+		return Creature{}, translateError(err)
 	}
-	defer db.Close()
 
 	stmt, err := db.PrepareContext(ctx, "insert into creatures (name, description) values ($1, $2) returning id")
 	if err != nil {
-		return Creature{}, err
+		return Creature{}, translateError(err)
 	}
 	defer stmt.Close()
 	res := stmt.QueryRowContext(ctx, name, description)
 	var id int64
 	err = res.Scan(&id)
 	if err != nil {
-		return Creature{}, err
+		return Creature{}, translateError(err)
 	}
 	return Creature{
 		ID:          id,
@@ -45,16 +81,69 @@ func (c *CreatureRepo) CreateCreature(ctx context.Context, name, description str
 	}, nil
 }
 
-func (c *CreatureRepo) GetCreature(ctx context.Context, id int64) (CreatureLookupResult, error) {
+// CreateCreatures makes a batch of creates in a single round trip, returning the creatures made in the order they were
+// given. IDs given are ignored. Either every creature is made or, should any of them fail, none are.
+func (c *CreatureRepo) CreateCreatures(ctx context.Context, creatures []Creature) ([]Creature, error) {
+	if len(creatures) == 0 {
+		return []Creature{}, nil
+	}
+	names := make([]string, 0, len(creatures))
+	descriptions := make([]string, 0, len(creatures))
+	for _, creature := range creatures {
+		names = append(names, creature.Name)
+		descriptions = append(descriptions, creature.Description)
+	}
+
 	db, err := c.connectionOpener.OpenConnection()
 	if err != nil {
-		return CreatureLookupResult{}, err
+		return nil, translateError(err)
+	}
+
+	stmt, err := db.PrepareContext(ctx, "insert into creatures (name, description) select * from unnest($1::varchar[], $2::text[]) returning id, name")
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, pq.Array(names), pq.Array(descriptions))
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+	// the order rows are returned in isn't guaranteed, but names are unique so ids can be matched up by them instead
+	ids := make(map[string]int64, len(creatures))
+	for rows.Next() {
+		var id int64
+		var name string
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		ids[name] = id
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	ret := make([]Creature, 0, len(creatures))
+	for i := range creatures {
+		ret = append(ret, Creature{
+			ID:          ids[names[i]],
+			Name:        names[i],
+			Description: descriptions[i],
+		})
+	}
+	return ret, nil
+}
+
+func (c *CreatureRepo) GetCreature(ctx context.Context, id int64) (CreatureLookupResult, error) {
+	db, err := c.openReadConnection(ctx)
+	if err != nil {
+		return CreatureLookupResult{}, translateError(err)
 	}
-	defer db.Close()
 
 	stmt, err := db.PrepareContext(ctx, "select name, description from creatures where id=$1")
 	if err != nil {
-		return CreatureLookupResult{}, err
+		return CreatureLookupResult{}, translateError(err)
 	}
 	defer stmt.Close()
 	row := stmt.QueryRowContext(ctx, id)
@@ -66,7 +155,7 @@ func (c *CreatureRepo) GetCreature(ctx context.Context, id int64) (CreatureLooku
 				ResultFound: false,
 			}, nil
 		} else {
-			return CreatureLookupResult{}, err
+			return CreatureLookupResult{}, translateError(err)
 		}
 	}
 	return CreatureLookupResult{
@@ -78,3 +167,223 @@ func (c *CreatureRepo) GetCreature(ctx context.Context, id int64) (CreatureLooku
 		},
 	}, nil
 }
+
+// GetCreatures looks up a batch of creatures in a single round trip, every requested id will be present in the result
+func (c *CreatureRepo) GetCreatures(ctx context.Context, ids []int64) (map[int64]CreatureLookupResult, error) {
+	ret := make(map[int64]CreatureLookupResult, len(ids))
+	if len(ids) == 0 {
+		return ret, nil
+	}
+	for _, id := range ids {
+		ret[id] = CreatureLookupResult{
+			ResultFound: false,
+		}
+	}
+
+	db, err := c.openReadConnection(ctx)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	stmt, err := db.PrepareContext(ctx, "select id, name, description from creatures where id = any($1)")
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, pq.Array(ids))
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var creature Creature
+		err = rows.Scan(&creature.ID, &creature.Name, &creature.Description)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		ret[creature.ID] = CreatureLookupResult{
+			ResultFound: true,
+			Creature:    creature,
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+	return ret, nil
+}
+
+func (c *CreatureRepo) GetCreatureByName(ctx context.Context, name string) (CreatureLookupResult, error) {
+	db, err := c.openReadConnection(ctx)
+	if err != nil {
+		return CreatureLookupResult{}, translateError(err)
+	}
+
+	stmt, err := db.PrepareContext(ctx, "select id, description from creatures where name=$1")
+	if err != nil {
+		return CreatureLookupResult{}, translateError(err)
+	}
+	defer stmt.Close()
+	row := stmt.QueryRowContext(ctx, name)
+	var id int64
+	var description string
+	err = row.Scan(&id, &description)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return CreatureLookupResult{
+				ResultFound: false,
+			}, nil
+		} else {
+			return CreatureLookupResult{}, translateError(err)
+		}
+	}
+	return CreatureLookupResult{
+		ResultFound: true,
+		Creature: Creature{
+			ID:          id,
+			Name:        name,
+			Description: description,
+		},
+	}, nil
+}
+
+func (c *CreatureRepo) UpdateCreature(ctx context.Context, id int64, update CreatureUpdate) (CreatureUpdateResult, error) {
+	db, err := c.connectionOpener.OpenConnection()
+	if err != nil {
+		return CreatureUpdateResult{}, translateError(err)
+	}
+
+	stmt, err := db.PrepareContext(ctx, "update creatures set name=coalesce($2, name), description=coalesce($3, description) where id=$1 returning name, description")
+	if err != nil {
+		return CreatureUpdateResult{}, translateError(err)
+	}
+	defer stmt.Close()
+	row := stmt.QueryRowContext(ctx, id, update.Name, update.Description)
+	var name, description string
+	err = row.Scan(&name, &description)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return CreatureUpdateResult{
+				ResultFound: false,
+			}, nil
+		} else {
+			return CreatureUpdateResult{}, translateError(err)
+		}
+	}
+	return CreatureUpdateResult{
+		ResultFound: true,
+		Creature: Creature{
+			ID:          id,
+			Name:        name,
+			Description: description,
+		},
+	}, nil
+}
+
+func (c *CreatureRepo) DeleteCreature(ctx context.Context, id int64) (CreatureDeleteResult, error) {
+	db, err := c.connectionOpener.OpenConnection()
+	if err != nil {
+		return CreatureDeleteResult{}, translateError(err)
+	}
+
+	stmt, err := db.PrepareContext(ctx, "delete from creatures where id=$1")
+	if err != nil {
+		return CreatureDeleteResult{}, translateError(err)
+	}
+	defer stmt.Close()
+	res, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		return CreatureDeleteResult{}, translateError(err)
+	}
+	impacted, err := res.RowsAffected()
+	if err != nil {
+		return CreatureDeleteResult{}, translateError(err)
+	}
+	return CreatureDeleteResult{
+		ResultFound: impacted > 0,
+	}, nil
+}
+
+func (c *CreatureRepo) ListCreatures(ctx context.Context, options ListOptions) (CreaturePage, error) {
+	pageSize := c.pageSize(options.PageSize)
+	// we always ask for one more row than needed, if it shows up we know there is another page
+	query, args, err := c.listQuery(options, pageSize+1)
+	if err != nil {
+		return CreaturePage{}, translateError(err)
+	}
+
+	db, err := c.openReadConnection(ctx)
+	if err != nil {
+		return CreaturePage{}, translateError(err)
+	}
+
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return CreaturePage{}, translateError(err)
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return CreaturePage{}, translateError(err)
+	}
+	defer rows.Close()
+	creatures := make([]Creature, 0, pageSize+1)
+	for rows.Next() {
+		var creature Creature
+		err = rows.Scan(&creature.ID, &creature.Name, &creature.Description)
+		if err != nil {
+			return CreaturePage{}, translateError(err)
+		}
+		creatures = append(creatures, creature)
+	}
+	if err = rows.Err(); err != nil {
+		return CreaturePage{}, translateError(err)
+	}
+
+	ret := CreaturePage{
+		Creatures: creatures,
+	}
+	if len(creatures) > pageSize {
+		ret.Creatures = creatures[:pageSize]
+		ret.NextCursor = newListCursor(options.OrderBy, ret.Creatures[len(ret.Creatures)-1]).encode()
+	}
+	return ret, nil
+}
+
+func (c *CreatureRepo) openReadConnection(ctx context.Context) (*sql.DB, error) {
+	if opener, ok := c.connectionOpener.(ReadConnectionOpener); ok {
+		return opener.OpenReadConnection(ctx)
+	}
+	return c.connectionOpener.OpenConnection()
+}
+
+func (c *CreatureRepo) listQuery(options ListOptions, limit int) (string, []any, error) {
+	if options.Cursor == "" {
+		switch options.OrderBy {
+		case OrderByID:
+			return "select id, name, description from creatures order by id limit $1", []any{limit}, nil
+		case OrderByName:
+			return "select id, name, description from creatures order by name limit $1", []any{limit}, nil
+		default:
+			return "", nil, fmt.Errorf("%w: unsupported list order %d", ErrValidation, options.OrderBy)
+		}
+	}
+	cursor, err := decodeListCursor(options.Cursor, options.OrderBy)
+	if err != nil {
+		return "", nil, err
+	}
+	switch options.OrderBy {
+	case OrderByID:
+		return "select id, name, description from creatures where id > $1 order by id limit $2", []any{cursor.ID, limit}, nil
+	case OrderByName:
+		return "select id, name, description from creatures where name > $1 order by name limit $2", []any{cursor.Name, limit}, nil
+	default:
+		return "", nil, fmt.Errorf("%w: unsupported list order %d", ErrValidation, options.OrderBy)
+	}
+}
+
+func (c *CreatureRepo) pageSize(requested int) int {
+	if requested <= 0 {
+		return c.defaultPageSize
+	}
+	return min(requested, c.maxPageSize)
+}
@@ -2,7 +2,10 @@ package srp
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
@@ -20,6 +23,7 @@ func TestCreatureRepo_CreateCreature(t *testing.T) {
 	connectionCfg, err := db.ConnectionParamsFromEnv()
 	require.NoError(t, err)
 	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
 
 	testInstance := NewCreatureRepo(connectionOpener)
 	creature, err := testInstance.CreateCreature(ctx, name, description)
@@ -29,7 +33,6 @@ func TestCreatureRepo_CreateCreature(t *testing.T) {
 
 	conn, err := connectionOpener.OpenConnection()
 	require.NoError(t, err)
-	defer conn.Close()
 
 	//let's ensure data was written to postgres as expected
 	stmt, err := conn.PrepareContext(ctx, "select name, description from creatures where id=$1")
@@ -43,6 +46,45 @@ func TestCreatureRepo_CreateCreature(t *testing.T) {
 	assert.Equal(t, description, pDescription)
 }
 
+func TestCreatureRepo_CreateCreatures(t *testing.T) {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("creature_test_%s", uuid.NewString())
+	toCreate := []Creature{
+		{Name: prefix + "_a", Description: "a creature for testing purposes"},
+		{Name: prefix + "_b", Description: "another creature for testing purposes"},
+		{Name: prefix + "_c", Description: ""},
+	}
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepo(connectionOpener)
+	created, err := testInstance.CreateCreatures(ctx, toCreate)
+	require.NoError(t, err)
+	require.Len(t, created, len(toCreate))
+	for i, creature := range created {
+		assert.Equal(t, toCreate[i].Name, creature.Name)
+		assert.Equal(t, toCreate[i].Description, creature.Description)
+		result, err := testInstance.GetCreature(ctx, creature.ID)
+		require.NoError(t, err)
+		assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: creature}, result)
+	}
+
+	// a name already in use fails the whole batch, leaving nothing behind
+	fresh := Creature{Name: prefix + "_d", Description: "a creature for testing purposes"}
+	_, err = testInstance.CreateCreatures(ctx, []Creature{fresh, toCreate[0]})
+	assert.ErrorIs(t, err, ErrDuplicateName)
+	result, err := testInstance.GetCreatureByName(ctx, fresh.Name)
+	require.NoError(t, err)
+	assert.False(t, result.ResultFound)
+
+	created, err = testInstance.CreateCreatures(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, created)
+}
+
 func TestCreatureRepo_GetCreature_ResultFound(t *testing.T) {
 	ctx := context.Background()
 	name := fmt.Sprintf("creature_test_%s", uuid.NewString())
@@ -51,12 +93,12 @@ func TestCreatureRepo_GetCreature_ResultFound(t *testing.T) {
 	connectionCfg, err := db.ConnectionParamsFromEnv()
 	require.NoError(t, err)
 	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
 
 	testInstance := NewCreatureRepo(connectionOpener)
 
 	conn, err := connectionOpener.OpenConnection()
 	require.NoError(t, err)
-	defer conn.Close()
 
 	stmt, err := conn.PrepareContext(ctx, "insert into creatures (name, description) values ($1, $2)")
 	require.NoError(t, err)
@@ -87,6 +129,7 @@ func TestCreatureRepo_GetCreature_NoResultFound(t *testing.T) {
 	connectionCfg, err := db.ConnectionParamsFromEnv()
 	require.NoError(t, err)
 	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
 
 	testInstance := NewCreatureRepo(connectionOpener)
 
@@ -95,3 +138,447 @@ func TestCreatureRepo_GetCreature_NoResultFound(t *testing.T) {
 	require.NoError(t, err)
 	assert.False(t, result.ResultFound)
 }
+
+func TestCreatureRepo_GetCreatures(t *testing.T) {
+	ctx := context.Background()
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepo(connectionOpener)
+
+	conn, err := connectionOpener.OpenConnection()
+	require.NoError(t, err)
+	expected := make(map[int64]CreatureLookupResult)
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("creature_test_%s", uuid.NewString())
+		id := insertTestCreature(ctx, t, conn, name, "a creature for testing purposes")
+		defer deleteTestCreature(ctx, t, conn, id)
+		ids = append(ids, id)
+		expected[id] = CreatureLookupResult{
+			ResultFound: true,
+			Creature: Creature{
+				ID:          id,
+				Name:        name,
+				Description: "a creature for testing purposes",
+			},
+		}
+	}
+	ids = append(ids, -1)
+	expected[-1] = CreatureLookupResult{
+		ResultFound: false,
+	}
+
+	result, err := testInstance.GetCreatures(ctx, ids)
+	require.NoError(t, err)
+	assert.Equal(t, expected, result)
+}
+
+func TestCreatureRepo_GetCreatures_NoIDs(t *testing.T) {
+	ctx := context.Background()
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepo(connectionOpener)
+
+	result, err := testInstance.GetCreatures(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestCreatureRepo_GetCreatureByName_ResultFound(t *testing.T) {
+	ctx := context.Background()
+	name := fmt.Sprintf("creature_test_%s", uuid.NewString())
+	description := "a creature for testing purposes"
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepo(connectionOpener)
+
+	conn, err := connectionOpener.OpenConnection()
+	require.NoError(t, err)
+	id := insertTestCreature(ctx, t, conn, name, description)
+	defer deleteTestCreature(ctx, t, conn, id)
+
+	result, err := testInstance.GetCreatureByName(ctx, name)
+	require.NoError(t, err)
+	assert.True(t, result.ResultFound)
+	assert.Equal(t, Creature{
+		ID:          id,
+		Name:        name,
+		Description: description,
+	}, result.Creature)
+}
+
+func TestCreatureRepo_GetCreatureByName_NoResultFound(t *testing.T) {
+	ctx := context.Background()
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepo(connectionOpener)
+
+	result, err := testInstance.GetCreatureByName(ctx, fmt.Sprintf("creature_test_%s", uuid.NewString()))
+	require.NoError(t, err)
+	assert.False(t, result.ResultFound)
+}
+
+func TestCreatureRepo_UpdateCreature(t *testing.T) {
+	name := fmt.Sprintf("creature_test_%s", uuid.NewString())
+	description := "a creature for testing purposes"
+	newName := fmt.Sprintf("creature_test_%s", uuid.NewString())
+	newDescription := "a creature that has been updated"
+
+	testCases := []struct {
+		name           string
+		update         CreatureUpdate
+		expectedResult Creature
+	}{
+		{
+			name: "name and description",
+			update: CreatureUpdate{
+				Name:        &newName,
+				Description: &newDescription,
+			},
+			expectedResult: Creature{
+				Name:        newName,
+				Description: newDescription,
+			},
+		},
+		{
+			name: "name only",
+			update: CreatureUpdate{
+				Name: &newName,
+			},
+			expectedResult: Creature{
+				Name:        newName,
+				Description: description,
+			},
+		},
+		{
+			name: "description only",
+			update: CreatureUpdate{
+				Description: &newDescription,
+			},
+			expectedResult: Creature{
+				Name:        name,
+				Description: newDescription,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			connectionCfg, err := db.ConnectionParamsFromEnv()
+			require.NoError(t, err)
+			connectionOpener := db.NewConnectionOpener(connectionCfg)
+			defer connectionOpener.Close()
+
+			testInstance := NewCreatureRepo(connectionOpener)
+
+			conn, err := connectionOpener.OpenConnection()
+			require.NoError(t, err)
+			id := insertTestCreature(ctx, t, conn, name, description)
+			defer deleteTestCreature(ctx, t, conn, id)
+
+			result, err := testInstance.UpdateCreature(ctx, id, tc.update)
+			require.NoError(t, err)
+			assert.True(t, result.ResultFound)
+			expected := tc.expectedResult
+			expected.ID = id
+			assert.Equal(t, expected, result.Creature)
+
+			//let's ensure data was written to postgres as expected
+			row := conn.QueryRowContext(ctx, "select name, description from creatures where id=$1", id)
+			var pName, pDescription string
+			err = row.Scan(&pName, &pDescription)
+			require.NoError(t, err)
+			assert.Equal(t, expected.Name, pName)
+			assert.Equal(t, expected.Description, pDescription)
+		})
+	}
+}
+
+func TestCreatureRepo_UpdateCreature_NoResultFound(t *testing.T) {
+	ctx := context.Background()
+	newName := fmt.Sprintf("creature_test_%s", uuid.NewString())
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepo(connectionOpener)
+
+	result, err := testInstance.UpdateCreature(ctx, -1, CreatureUpdate{Name: &newName})
+	require.NoError(t, err)
+	assert.False(t, result.ResultFound)
+}
+
+func TestCreatureRepo_DeleteCreature_ResultFound(t *testing.T) {
+	ctx := context.Background()
+	name := fmt.Sprintf("creature_test_%s", uuid.NewString())
+	description := "a creature for testing purposes"
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepo(connectionOpener)
+
+	conn, err := connectionOpener.OpenConnection()
+	require.NoError(t, err)
+	id := insertTestCreature(ctx, t, conn, name, description)
+
+	result, err := testInstance.DeleteCreature(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, result.ResultFound)
+
+	row := conn.QueryRowContext(ctx, "select count(*) from creatures where id=$1", id)
+	var count int
+	err = row.Scan(&count)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestCreatureRepo_DeleteCreature_NoResultFound(t *testing.T) {
+	ctx := context.Background()
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepo(connectionOpener)
+
+	result, err := testInstance.DeleteCreature(ctx, -1)
+	require.NoError(t, err)
+	assert.False(t, result.ResultFound)
+}
+
+func TestCreatureRepo_ListCreatures_OrderByName(t *testing.T) {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("creature_list_test_%s", uuid.NewString())
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepo(connectionOpener)
+
+	conn, err := connectionOpener.OpenConnection()
+	require.NoError(t, err)
+	var expected []Creature
+	// insert out of order so we know the sorting is coming from the query
+	for _, i := range []int{3, 0, 4, 1, 2} {
+		name := fmt.Sprintf("%s_%d", prefix, i)
+		id := insertTestCreature(ctx, t, conn, name, "a creature for testing purposes")
+		defer deleteTestCreature(ctx, t, conn, id)
+		expected = append(expected, Creature{ID: id, Name: name, Description: "a creature for testing purposes"})
+	}
+	sort.Slice(expected, func(i, j int) bool {
+		return expected[i].Name < expected[j].Name
+	})
+
+	// other tests are free to create creatures while we run, so start the listing right before our batch
+	cursor := newListCursor(OrderByName, Creature{Name: prefix}).encode()
+	var pages [][]Creature
+	for i := 0; i < 3; i++ {
+		page, err := testInstance.ListCreatures(ctx, ListOptions{
+			PageSize: 2,
+			OrderBy:  OrderByName,
+			Cursor:   cursor,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, page.Creatures)
+		pages = append(pages, page.Creatures)
+		cursor = page.NextCursor
+	}
+	assert.Equal(t, expected[0:2], pages[0])
+	assert.Equal(t, expected[2:4], pages[1])
+	assert.Equal(t, expected[4], pages[2][0])
+}
+
+func TestCreatureRepo_ListCreatures_OrderByID(t *testing.T) {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("creature_list_test_%s", uuid.NewString())
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepo(connectionOpener)
+
+	conn, err := connectionOpener.OpenConnection()
+	require.NoError(t, err)
+	var expected []Creature
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("%s_%d", prefix, i)
+		id := insertTestCreature(ctx, t, conn, name, "a creature for testing purposes")
+		defer deleteTestCreature(ctx, t, conn, id)
+		expected = append(expected, Creature{ID: id, Name: name, Description: "a creature for testing purposes"})
+	}
+
+	// other tests may sneak rows in between ours, so page until we have seen all of our creatures and only look at those
+	cursor := newListCursor(OrderByID, Creature{ID: expected[0].ID - 1}).encode()
+	var listed []Creature
+	lastID := expected[0].ID - 1
+	for len(listed) < len(expected) {
+		page, err := testInstance.ListCreatures(ctx, ListOptions{
+			PageSize: 2,
+			OrderBy:  OrderByID,
+			Cursor:   cursor,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, page.Creatures)
+		assert.LessOrEqual(t, len(page.Creatures), 2)
+		for _, creature := range page.Creatures {
+			assert.Greater(t, creature.ID, lastID)
+			lastID = creature.ID
+			if strings.HasPrefix(creature.Name, prefix) {
+				listed = append(listed, creature)
+			}
+		}
+		cursor = page.NextCursor
+	}
+	assert.Equal(t, expected, listed)
+}
+
+func TestCreatureRepo_ListCreatures_PageSizeLimits(t *testing.T) {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("creature_list_test_%s", uuid.NewString())
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepoWithOptions(connectionOpener, CreatureRepoOptions{
+		DefaultPageSize: 2,
+		MaxPageSize:     3,
+	})
+
+	conn, err := connectionOpener.OpenConnection()
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		id := insertTestCreature(ctx, t, conn, fmt.Sprintf("%s_%d", prefix, i), "a creature for testing purposes")
+		defer deleteTestCreature(ctx, t, conn, id)
+	}
+	cursor := newListCursor(OrderByName, Creature{Name: prefix}).encode()
+
+	page, err := testInstance.ListCreatures(ctx, ListOptions{
+		OrderBy: OrderByName,
+		Cursor:  cursor,
+	})
+	require.NoError(t, err)
+	assert.Len(t, page.Creatures, 2)
+	assert.NotEmpty(t, page.NextCursor)
+
+	page, err = testInstance.ListCreatures(ctx, ListOptions{
+		PageSize: 100,
+		OrderBy:  OrderByName,
+		Cursor:   cursor,
+	})
+	require.NoError(t, err)
+	assert.Len(t, page.Creatures, 3)
+	assert.NotEmpty(t, page.NextCursor)
+}
+
+func TestCreatureRepo_ListCreatures_InvalidCursor(t *testing.T) {
+	ctx := context.Background()
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepo(connectionOpener)
+
+	_, err = testInstance.ListCreatures(ctx, ListOptions{
+		OrderBy: OrderByID,
+		Cursor:  newListCursor(OrderByName, Creature{Name: "bob"}).encode(),
+	})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func insertTestCreature(ctx context.Context, t *testing.T, conn *sql.DB, name, description string) int64 {
+	row := conn.QueryRowContext(ctx, "insert into creatures (name, description) values ($1, $2) returning id", name, description)
+	var id int64
+	err := row.Scan(&id)
+	require.NoError(t, err)
+	return id
+}
+
+func deleteTestCreature(ctx context.Context, t *testing.T, conn *sql.DB, id int64) {
+	_, err := conn.ExecContext(ctx, "delete from creatures where id=$1", id)
+	require.NoError(t, err)
+}
+
+func TestCreatureRepo_ReadReplica(t *testing.T) {
+	ctx := context.Background()
+	name := fmt.Sprintf("creature_test_%s", uuid.NewString())
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	primaryOpener := db.NewConnectionOpener(connectionCfg)
+	primary, err := primaryOpener.OpenConnection()
+	require.NoError(t, err)
+
+	// the replica is stood in for by a schema of its own, holding a copy of the creature that can be told apart
+	schema := fmt.Sprintf("replica_test_%s", strings.ReplaceAll(uuid.NewString(), "-", ""))
+	_, err = primary.ExecContext(ctx, fmt.Sprintf("create schema %s", schema))
+	require.NoError(t, err)
+	defer func() {
+		_, err := primary.ExecContext(ctx, fmt.Sprintf("drop schema %s cascade", schema))
+		assert.NoError(t, err)
+	}()
+	_, err = primary.ExecContext(ctx, fmt.Sprintf("create table %s.creatures (like public.creatures including all)", schema))
+	require.NoError(t, err)
+	replicaCfg := connectionCfg
+	replicaCfg.SearchPath = schema
+
+	connectionOpener := db.NewRoutingConnectionOpener(primaryOpener, []*db.ConnectionOpener{db.NewConnectionOpener(replicaCfg)})
+	defer connectionOpener.Close()
+	testInstance := NewCreatureRepo(connectionOpener)
+
+	creature, err := testInstance.CreateCreature(ctx, name, "as seen by the primary")
+	require.NoError(t, err)
+	_, err = primary.ExecContext(ctx, fmt.Sprintf("insert into %s.creatures (id, name, description) values ($1, $2, $3)", schema), creature.ID, name, "as seen by the replica")
+	require.NoError(t, err)
+
+	fromReplica := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: creature.ID, Name: name, Description: "as seen by the replica"},
+	}
+	result, err := testInstance.GetCreature(ctx, creature.ID)
+	require.NoError(t, err)
+	assert.Equal(t, fromReplica, result)
+	results, err := testInstance.GetCreatures(ctx, []int64{creature.ID})
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]CreatureLookupResult{creature.ID: fromReplica}, results)
+	result, err = testInstance.GetCreatureByName(ctx, name)
+	require.NoError(t, err)
+	assert.Equal(t, fromReplica, result)
+	page, err := testInstance.ListCreatures(ctx, ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []Creature{fromReplica.Creature}, page.Creatures)
+
+	result, err = testInstance.GetCreature(db.WithReadYourWrites(ctx), creature.ID)
+	require.NoError(t, err)
+	assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: creature}, result)
+}
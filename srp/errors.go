@@ -0,0 +1,88 @@
+package srp
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateName = errors.New("creature name already in use")
+	ErrNotFound      = errors.New("creature not found")
+	ErrValidation    = errors.New("invalid creature data")
+	ErrUnavailable   = errors.New("creature storage unavailable")
+)
+
+const creatureNameConstraint = "ux_creatures_name"
+
+// RepoError pairs one of the sentinel errors above with whatever caused it, so callers can check the kind of failure
+// with errors.Is while still being able to dig out the underlying *pq.Error via errors.As if they need to.
+type RepoError struct {
+	Kind  error
+	Cause error
+}
+
+func (r *RepoError) Error() string {
+	return r.Kind.Error() + ": " + r.Cause.Error()
+}
+
+func (r *RepoError) Unwrap() []error {
+	return []error{r.Kind, r.Cause}
+}
+
+// translateError maps driver level errors onto the error taxonomy above, anything it does not recognize is returned
+// untouched
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var repoErr *RepoError
+	if errors.As(err, &repoErr) {
+		return err
+	}
+	kind := errorKind(err)
+	if kind == nil {
+		return err
+	}
+	return &RepoError{
+		Kind:  kind,
+		Cause: err,
+	}
+}
+
+func errorKind(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErrorKind(pqErr)
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrUnavailable
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+func pqErrorKind(err *pq.Error) error {
+	switch {
+	case err.Code == "23505" && err.Constraint == creatureNameConstraint:
+		return ErrDuplicateName
+	case err.Code.Class() == "23", err.Code.Class() == "22":
+		// integrity constraint violations & data exceptions
+		return ErrValidation
+	case err.Code.Class() == "02", err.Code == "P0002":
+		// no data
+		return ErrNotFound
+	case err.Code.Class() == "08", err.Code.Class() == "53", err.Code.Class() == "58", err.Code == "57P01", err.Code == "57P02", err.Code == "57P03":
+		// connection exceptions, insufficient resources, system errors & the server going away
+		return ErrUnavailable
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,203 @@
+package srp
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jonsabados/srp-sample/db"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslateError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		input        error
+		expectedKind error
+	}{
+		{
+			name:         "duplicate name",
+			input:        &pq.Error{Code: "23505", Constraint: "ux_creatures_name"},
+			expectedKind: ErrDuplicateName,
+		},
+		{
+			name:         "some other unique violation",
+			input:        &pq.Error{Code: "23505", Constraint: "creatures_pkey"},
+			expectedKind: ErrValidation,
+		},
+		{
+			name:         "not null violation",
+			input:        &pq.Error{Code: "23502"},
+			expectedKind: ErrValidation,
+		},
+		{
+			name:         "bad data",
+			input:        &pq.Error{Code: "22021"},
+			expectedKind: ErrValidation,
+		},
+		{
+			name:         "no data found",
+			input:        &pq.Error{Code: "P0002"},
+			expectedKind: ErrNotFound,
+		},
+		{
+			name:         "connection failure",
+			input:        &pq.Error{Code: "08006"},
+			expectedKind: ErrUnavailable,
+		},
+		{
+			name:         "too many connections",
+			input:        &pq.Error{Code: "53300"},
+			expectedKind: ErrUnavailable,
+		},
+		{
+			name:         "server shutting down",
+			input:        &pq.Error{Code: "57P01"},
+			expectedKind: ErrUnavailable,
+		},
+		{
+			name:         "bad connection",
+			input:        driver.ErrBadConn,
+			expectedKind: ErrUnavailable,
+		},
+		{
+			name:         "network error",
+			input:        &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			expectedKind: ErrUnavailable,
+		},
+		{
+			name:         "wrapped network error",
+			input:        fmt.Errorf("oh no: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}),
+			expectedKind: ErrUnavailable,
+		},
+		{
+			name:  "syntax error",
+			input: &pq.Error{Code: "42601"},
+		},
+		{
+			name:  "something else entirely",
+			input: errors.New("what even is this"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := translateError(tc.input)
+			if tc.expectedKind == nil {
+				assert.Same(t, tc.input, result)
+				return
+			}
+			assert.ErrorIs(t, result, tc.expectedKind)
+			assert.ErrorIs(t, result, tc.input)
+			var repoErr *RepoError
+			require.ErrorAs(t, result, &repoErr)
+			assert.Equal(t, tc.expectedKind, repoErr.Kind)
+			// translating twice should be harmless
+			assert.Same(t, result, translateError(result))
+		})
+	}
+}
+
+func TestTranslateError_Nil(t *testing.T) {
+	assert.NoError(t, translateError(nil))
+}
+
+func TestCreatureRepo_CreateCreature_DuplicateName(t *testing.T) {
+	ctx := context.Background()
+	name := fmt.Sprintf("creature_test_%s", uuid.NewString())
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepo(connectionOpener)
+
+	creature, err := testInstance.CreateCreature(ctx, name, "the original")
+	require.NoError(t, err)
+	conn, err := connectionOpener.OpenConnection()
+	require.NoError(t, err)
+	defer deleteTestCreature(ctx, t, conn, creature.ID)
+
+	_, err = testInstance.CreateCreature(ctx, name, "the imposter")
+	assert.ErrorIs(t, err, ErrDuplicateName)
+	var pqErr *pq.Error
+	require.ErrorAs(t, err, &pqErr)
+	assert.Equal(t, pq.ErrorCode("23505"), pqErr.Code)
+}
+
+func TestCreatureRepo_UpdateCreature_DuplicateName(t *testing.T) {
+	ctx := context.Background()
+	name := fmt.Sprintf("creature_test_%s", uuid.NewString())
+	otherName := fmt.Sprintf("creature_test_%s", uuid.NewString())
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepo(connectionOpener)
+
+	conn, err := connectionOpener.OpenConnection()
+	require.NoError(t, err)
+	id := insertTestCreature(ctx, t, conn, name, "a creature for testing purposes")
+	defer deleteTestCreature(ctx, t, conn, id)
+	otherID := insertTestCreature(ctx, t, conn, otherName, "a creature for testing purposes")
+	defer deleteTestCreature(ctx, t, conn, otherID)
+
+	_, err = testInstance.UpdateCreature(ctx, id, CreatureUpdate{Name: &otherName})
+	assert.ErrorIs(t, err, ErrDuplicateName)
+}
+
+func TestCreatureRepo_CreateCreature_Validation(t *testing.T) {
+	ctx := context.Background()
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepo(connectionOpener)
+
+	// postgres refuses to store nul characters in text columns
+	_, err = testInstance.CreateCreature(ctx, fmt.Sprintf("creature_test_%s\x00", uuid.NewString()), "a creature for testing purposes")
+	assert.ErrorIs(t, err, ErrValidation)
+}
+
+func TestTranslateError_NotFound_Postgres(t *testing.T) {
+	ctx := context.Background()
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	conn, err := connectionOpener.OpenConnection()
+	require.NoError(t, err)
+
+	// nothing the repo does today raises no_data_found, so get postgres to raise one for us
+	_, err = conn.ExecContext(ctx, "do $$ begin raise exception using errcode = 'no_data_found'; end $$")
+	require.Error(t, err)
+	assert.ErrorIs(t, translateError(err), ErrNotFound)
+}
+
+func TestCreatureRepo_GetCreature_Unavailable(t *testing.T) {
+	ctx := context.Background()
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	// nothing should be listening here
+	connectionCfg.Port = 1
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	testInstance := NewCreatureRepo(connectionOpener)
+
+	_, err = testInstance.GetCreature(ctx, 1)
+	assert.ErrorIs(t, err, ErrUnavailable)
+}
@@ -0,0 +1,169 @@
+package srp
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+type EvictionPolicy int
+
+const (
+	// EvictLRU evicts the entry that has gone the longest without being read or written
+	EvictLRU EvictionPolicy = iota
+	// EvictLFU evicts the entry that has been read or written the fewest times, oldest first when tied
+	EvictLFU
+)
+
+// evictionTracker keeps track of cache usage so that a bounded cache knows what to throw away when it fills up
+type evictionTracker interface {
+	add(id int64)
+	touch(id int64)
+	remove(id int64)
+	victim() (int64, bool)
+}
+
+func newEvictionTracker(policy EvictionPolicy) evictionTracker {
+	switch policy {
+	case EvictLFU:
+		return newLFUTracker()
+	default:
+		return newLRUTracker()
+	}
+}
+
+type lruTracker struct {
+	// front of the list is the most recently used
+	order    *list.List
+	elements map[int64]*list.Element
+}
+
+func newLRUTracker() *lruTracker {
+	return &lruTracker{
+		order:    list.New(),
+		elements: make(map[int64]*list.Element),
+	}
+}
+
+func (l *lruTracker) add(id int64) {
+	if element, tracked := l.elements[id]; tracked {
+		l.order.MoveToFront(element)
+		return
+	}
+	l.elements[id] = l.order.PushFront(id)
+}
+
+func (l *lruTracker) touch(id int64) {
+	if element, tracked := l.elements[id]; tracked {
+		l.order.MoveToFront(element)
+	}
+}
+
+func (l *lruTracker) remove(id int64) {
+	if element, tracked := l.elements[id]; tracked {
+		l.order.Remove(element)
+		delete(l.elements, id)
+	}
+}
+
+func (l *lruTracker) victim() (int64, bool) {
+	back := l.order.Back()
+	if back == nil {
+		return 0, false
+	}
+	return back.Value.(int64), true
+}
+
+type lfuEntry struct {
+	id    int64
+	uses  uint64
+	tick  uint64
+	index int
+}
+
+// lfuHeap is a min heap on use count, with the tick of the last use breaking ties
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int {
+	return len(h)
+}
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].uses == h[j].uses {
+		return h[i].tick < h[j].tick
+	}
+	return h[i].uses < h[j].uses
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	entry := x.(*lfuEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return entry
+}
+
+type lfuTracker struct {
+	entries map[int64]*lfuEntry
+	heap    lfuHeap
+	ticks   uint64
+}
+
+func newLFUTracker() *lfuTracker {
+	return &lfuTracker{
+		entries: make(map[int64]*lfuEntry),
+	}
+}
+
+func (l *lfuTracker) add(id int64) {
+	if _, tracked := l.entries[id]; tracked {
+		l.touch(id)
+		return
+	}
+	l.ticks++
+	entry := &lfuEntry{
+		id:   id,
+		uses: 1,
+		tick: l.ticks,
+	}
+	l.entries[id] = entry
+	heap.Push(&l.heap, entry)
+}
+
+func (l *lfuTracker) touch(id int64) {
+	entry, tracked := l.entries[id]
+	if !tracked {
+		return
+	}
+	l.ticks++
+	entry.uses++
+	entry.tick = l.ticks
+	heap.Fix(&l.heap, entry.index)
+}
+
+func (l *lfuTracker) remove(id int64) {
+	entry, tracked := l.entries[id]
+	if !tracked {
+		return
+	}
+	heap.Remove(&l.heap, entry.index)
+	delete(l.entries, id)
+}
+
+func (l *lfuTracker) victim() (int64, bool) {
+	if len(l.heap) == 0 {
+		return 0, false
+	}
+	return l.heap[0].id, true
+}
@@ -0,0 +1,112 @@
+package srp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvictionTracker(t *testing.T) {
+	testCases := []struct {
+		name           string
+		policy         EvictionPolicy
+		operations     func(tracker evictionTracker)
+		expectedVictim int64
+		expectVictim   bool
+	}{
+		{
+			name:       "lru, empty",
+			policy:     EvictLRU,
+			operations: func(tracker evictionTracker) {},
+		},
+		{
+			name:   "lru, oldest untouched entry",
+			policy: EvictLRU,
+			operations: func(tracker evictionTracker) {
+				tracker.add(1)
+				tracker.add(2)
+				tracker.add(3)
+			},
+			expectedVictim: 1,
+			expectVictim:   true,
+		},
+		{
+			name:   "lru, touching moves entries to the back of the line",
+			policy: EvictLRU,
+			operations: func(tracker evictionTracker) {
+				tracker.add(1)
+				tracker.add(2)
+				tracker.add(3)
+				tracker.touch(1)
+			},
+			expectedVictim: 2,
+			expectVictim:   true,
+		},
+		{
+			name:   "lru, removed entries are never victims",
+			policy: EvictLRU,
+			operations: func(tracker evictionTracker) {
+				tracker.add(1)
+				tracker.add(2)
+				tracker.remove(1)
+			},
+			expectedVictim: 2,
+			expectVictim:   true,
+		},
+		{
+			name:       "lfu, empty",
+			policy:     EvictLFU,
+			operations: func(tracker evictionTracker) {},
+		},
+		{
+			name:   "lfu, least used entry",
+			policy: EvictLFU,
+			operations: func(tracker evictionTracker) {
+				tracker.add(1)
+				tracker.add(2)
+				tracker.add(3)
+				tracker.touch(1)
+				tracker.touch(1)
+				tracker.touch(2)
+				tracker.touch(3)
+				tracker.touch(3)
+			},
+			expectedVictim: 2,
+			expectVictim:   true,
+		},
+		{
+			name:   "lfu, ties go to the oldest use",
+			policy: EvictLFU,
+			operations: func(tracker evictionTracker) {
+				tracker.add(1)
+				tracker.add(2)
+				tracker.add(3)
+				tracker.touch(1)
+				tracker.touch(2)
+			},
+			expectedVictim: 3,
+			expectVictim:   true,
+		},
+		{
+			name:   "lfu, removed entries are never victims",
+			policy: EvictLFU,
+			operations: func(tracker evictionTracker) {
+				tracker.add(1)
+				tracker.add(2)
+				tracker.touch(2)
+				tracker.remove(1)
+			},
+			expectedVictim: 2,
+			expectVictim:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tracker := newEvictionTracker(tc.policy)
+			tc.operations(tracker)
+			victim, ok := tracker.victim()
+			assert.Equal(t, tc.expectVictim, ok)
+			assert.Equal(t, tc.expectedVictim, victim)
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package srp
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultInvalidationChannel is the channel the creatures_notify_change trigger notifies on
+const DefaultInvalidationChannel = "creature_changes"
+
+const (
+	DefaultMinReconnectInterval = 10 * time.Second
+	DefaultMaxReconnectInterval = time.Minute
+	DefaultListenerPingInterval = 90 * time.Second
+)
+
+// Invalidator is anything holding cached creatures that can be told to drop them, such as CachingCreatureRepo and
+// TieredCreatureRepo
+type Invalidator interface {
+	Invalidate(ctx context.Context, id int64)
+	InvalidateAll(ctx context.Context)
+}
+
+type InvalidationListenerOptions struct {
+	// Channel is the channel to listen on, empty means DefaultInvalidationChannel
+	Channel string
+	// MinReconnectInterval and MaxReconnectInterval bound the backoff between attempts to re-establish a lost
+	// connection, zero means DefaultMinReconnectInterval and DefaultMaxReconnectInterval respectively
+	MinReconnectInterval time.Duration
+	MaxReconnectInterval time.Duration
+	// PingInterval controls how often the connection is checked on when no notifications are coming in, so that a dead
+	// connection gets noticed and replaced. Zero means DefaultListenerPingInterval.
+	PingInterval time.Duration
+	// Logger receives reports of connection problems and malformed notifications, nil means slog.Default()
+	Logger *slog.Logger
+	// CurrentDataSourceName, if set, is asked for the connection string whenever the listener fails to reconnect. Should
+	// it have changed, such as when credentials have been rotated, the listener starts over with it. db.ConnectionOpener's
+	// CurrentDataSourceName fits the bill.
+	CurrentDataSourceName func(ctx context.Context) (string, error)
+}
+
+// InvalidationListener evicts entries from subscribed repos as creatures are changed in the database, which keeps
+// caches in different processes from serving outdated creatures until their entries expire. Notifications are sent by
+// the trigger added in the 0002 migration. Notifications may be missed while the connection is down, so
+// subscribed repos are cleared out entirely whenever the connection is re-established.
+//
+// Repos also get notified about their own writes, which costs them a cache miss the next time the creature is looked up.
+type InvalidationListener struct {
+	// listener is replaced whenever the listener starts over with a new connection string, listenerMutex guards it
+	listener      *pq.Listener
+	listenerMutex sync.Mutex
+	// dataSourceName is what listener was created with, and is only touched by run once running
+	dataSourceName        string
+	currentDataSourceName func(ctx context.Context) (string, error)
+	channel               string
+	minReconnectInterval  time.Duration
+	maxReconnectInterval  time.Duration
+	// connectFailed is signalled by listeners failing to reconnect
+	connectFailed chan struct{}
+	pingInterval  time.Duration
+	logger        *slog.Logger
+
+	repos      []Invalidator
+	reposMutex sync.RWMutex
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewInvalidationListener connects to the database described by dataSourceName (see db.ConnectionOpener's
+// DataSourceName) and starts listening for changes. It waits for the initial connection to be made, giving up if ctx
+// is done first.
+func NewInvalidationListener(ctx context.Context, dataSourceName string, options InvalidationListenerOptions) (*InvalidationListener, error) {
+	channel := options.Channel
+	if channel == "" {
+		channel = DefaultInvalidationChannel
+	}
+	minReconnectInterval := options.MinReconnectInterval
+	if minReconnectInterval <= 0 {
+		minReconnectInterval = DefaultMinReconnectInterval
+	}
+	maxReconnectInterval := options.MaxReconnectInterval
+	if maxReconnectInterval <= 0 {
+		maxReconnectInterval = DefaultMaxReconnectInterval
+	}
+	pingInterval := options.PingInterval
+	if pingInterval <= 0 {
+		pingInterval = DefaultListenerPingInterval
+	}
+	logger := options.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	ret := &InvalidationListener{
+		dataSourceName:        dataSourceName,
+		currentDataSourceName: options.CurrentDataSourceName,
+		channel:               channel,
+		minReconnectInterval:  minReconnectInterval,
+		maxReconnectInterval:  maxReconnectInterval,
+		connectFailed:         make(chan struct{}, 1),
+		pingInterval:          pingInterval,
+		logger:                logger,
+		stop:                  make(chan struct{}),
+		done:                  make(chan struct{}),
+	}
+	ret.listener = ret.newListener(dataSourceName)
+
+	// Listen doesn't return until a connection has been made, which may be never
+	listening := make(chan error, 1)
+	go func() {
+		listening <- ret.listener.Listen(channel)
+	}()
+	select {
+	case err := <-listening:
+		if err != nil {
+			_ = ret.listener.Close()
+			return nil, err
+		}
+	case <-ctx.Done():
+		// closing the listener unblocks Listen, which then reports on the now closed listener into the void
+		_ = ret.listener.Close()
+		return nil, ctx.Err()
+	}
+
+	go ret.run()
+	return ret, nil
+}
+
+// Subscribe has the listener evict changed creatures from repo. Repos can't be unsubscribed, so the listener should
+// live no longer than the repos subscribed to it.
+func (l *InvalidationListener) Subscribe(repo Invalidator) {
+	l.reposMutex.Lock()
+	defer l.reposMutex.Unlock()
+	l.repos = append(l.repos, repo)
+}
+
+// Close stops listening and closes the connection to the database
+func (l *InvalidationListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.stop)
+		<-l.done
+		l.listenerMutex.Lock()
+		defer l.listenerMutex.Unlock()
+		err = l.listener.Close()
+	})
+	return err
+}
+
+func (l *InvalidationListener) run() {
+	defer close(l.done)
+	ctx := context.Background()
+	ticker := time.NewTicker(l.pingInterval)
+	defer ticker.Stop()
+	notify := l.listener.Notify
+	for {
+		select {
+		case notification, open := <-notify:
+			if !open {
+				return
+			}
+			ticker.Reset(l.pingInterval)
+			l.handle(ctx, notification)
+		case <-ticker.C:
+			go l.ping()
+		case <-l.connectFailed:
+			if replaced := l.startOver(ctx); replaced != nil {
+				notify = replaced.Notify
+			}
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+func (l *InvalidationListener) newListener(dataSourceName string) *pq.Listener {
+	return pq.NewListener(dataSourceName, l.minReconnectInterval, l.maxReconnectInterval, l.logEvent)
+}
+
+// startOver replaces the listener with one using the current connection string if it has changed, as the listener
+// would otherwise keep on trying to reconnect with credentials that no longer work. The replacement is returned, or nil
+// if the listener was left as is.
+func (l *InvalidationListener) startOver(ctx context.Context) *pq.Listener {
+	if l.currentDataSourceName == nil {
+		return nil
+	}
+	dataSourceName, err := l.currentDataSourceName(ctx)
+	if err != nil {
+		l.logger.ErrorContext(ctx, "error getting creature change listener connection string", "error", err)
+		return nil
+	}
+	if dataSourceName == l.dataSourceName {
+		return nil
+	}
+	l.logger.InfoContext(ctx, "creature change listener connection string changed, starting over")
+	l.dataSourceName = dataSourceName
+	replacement := l.newListener(dataSourceName)
+	l.listenerMutex.Lock()
+	previous := l.listener
+	l.listener = replacement
+	l.listenerMutex.Unlock()
+	_ = previous.Close()
+
+	go func() {
+		// Listen doesn't return until connected, with the replacement's own connection failures leading back here
+		if err := replacement.Listen(l.channel); err != nil {
+			// the listener having been replaced again, or closed, is the only reason this would happen
+			return
+		}
+		// as with a reconnect, there is no knowing what was missed
+		l.handle(ctx, nil)
+	}()
+	return replacement
+}
+
+func (l *InvalidationListener) handle(ctx context.Context, notification *pq.Notification) {
+	l.reposMutex.RLock()
+	defer l.reposMutex.RUnlock()
+	if notification == nil {
+		// a nil notification means the connection was re-established, and we have no way of knowing what we missed
+		for _, repo := range l.repos {
+			repo.InvalidateAll(ctx)
+		}
+		return
+	}
+	id, err := strconv.ParseInt(notification.Extra, 10, 64)
+	if err != nil {
+		l.logger.ErrorContext(ctx, "ignoring malformed creature change notification", "channel", notification.Channel, "payload", notification.Extra, "error", err)
+		return
+	}
+	for _, repo := range l.repos {
+		repo.Invalidate(ctx, id)
+	}
+}
+
+// ping checks on the connection, a failure here leads to pq.Listener reconnecting
+func (l *InvalidationListener) ping() {
+	l.listenerMutex.Lock()
+	listener := l.listener
+	l.listenerMutex.Unlock()
+	if err := listener.Ping(); err != nil {
+		l.logger.Warn("creature change listener ping failed", "error", err)
+	}
+}
+
+func (l *InvalidationListener) logEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventConnected:
+		l.logger.Info("creature change listener connected")
+	case pq.ListenerEventDisconnected:
+		l.logger.Warn("creature change listener disconnected", "error", err)
+	case pq.ListenerEventReconnected:
+		l.logger.Info("creature change listener reconnected")
+	case pq.ListenerEventConnectionAttemptFailed:
+		l.logger.Warn("creature change listener failed to connect", "error", err)
+		select {
+		case l.connectFailed <- struct{}{}:
+		default:
+			// the run loop has yet to get to the last failure
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package srp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonsabados/srp-sample/db"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvalidationListener_Handle(t *testing.T) {
+	ctx := context.Background()
+
+	lookupResult := func(id int64) CreatureLookupResult {
+		return CreatureLookupResult{
+			ResultFound: true,
+			Creature:    Creature{ID: id, Name: fmt.Sprintf("creature_%d", id)},
+		}
+	}
+
+	testCases := []struct {
+		name            string
+		notification    *pq.Notification
+		expectedReloads []int64
+		expectedLog     string
+	}{
+		{
+			name:            "creature changed",
+			notification:    &pq.Notification{Channel: DefaultInvalidationChannel, Extra: "1"},
+			expectedReloads: []int64{1},
+		},
+		{
+			name:            "reconnected",
+			notification:    nil,
+			expectedReloads: []int64{1, 2},
+		},
+		{
+			name:         "malformed",
+			notification: &pq.Notification{Channel: DefaultInvalidationChannel, Extra: "bob"},
+			expectedLog:  "ignoring malformed creature change notification",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logs := &bytes.Buffer{}
+			testInstance := &InvalidationListener{
+				logger: slog.New(slog.NewTextHandler(logs, nil)),
+			}
+
+			// two subscribed repos, each with creatures 1 and 2 cached
+			var rawRepos []*MockRawCreatureRepo
+			var repos []*CachingCreatureRepo
+			for i := 0; i < 2; i++ {
+				rawRepo := NewMockRawCreatureRepo(t)
+				repo := NewCachingCreatureRepo(rawRepo, time.Hour)
+				for _, id := range []int64{1, 2} {
+					rawRepo.EXPECT().GetCreature(mock.Anything, id).Return(lookupResult(id), nil).Once()
+					_, err := repo.GetCreature(ctx, id)
+					require.NoError(t, err)
+				}
+				testInstance.Subscribe(repo)
+				rawRepos = append(rawRepos, rawRepo)
+				repos = append(repos, repo)
+			}
+
+			testInstance.handle(ctx, tc.notification)
+
+			for i, repo := range repos {
+				for _, id := range tc.expectedReloads {
+					rawRepos[i].EXPECT().GetCreature(mock.Anything, id).Return(lookupResult(id), nil).Once()
+				}
+				// anything not expected to be reloaded should still be served from cache
+				for _, id := range []int64{1, 2} {
+					result, err := repo.GetCreature(ctx, id)
+					require.NoError(t, err)
+					assert.Equal(t, lookupResult(id), result)
+				}
+			}
+			if tc.expectedLog != "" {
+				assert.Contains(t, logs.String(), tc.expectedLog)
+			}
+		})
+	}
+}
+
+func TestNewInvalidationListener_GivesUpWithContext(t *testing.T) {
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	// nothing should be listening here
+	connectionCfg.Port = 1
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = NewInvalidationListener(ctx, connectionOpener.DataSourceName(), InvalidationListenerOptions{
+		Logger: slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)),
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestInvalidationListener_StartOver(t *testing.T) {
+	ctx := context.Background()
+	// nothing should be listening here, which doesn't matter as the listener is never waited on to connect
+	unreachable := "host=127.0.0.1 port=1 user=postgres password=first sslmode=disable"
+	current := unreachable
+	var currentErr error
+	testInstance := &InvalidationListener{
+		dataSourceName: unreachable,
+		currentDataSourceName: func(context.Context) (string, error) {
+			return current, currentErr
+		},
+		channel:              DefaultInvalidationChannel,
+		minReconnectInterval: time.Hour,
+		maxReconnectInterval: time.Hour,
+		connectFailed:        make(chan struct{}, 1),
+		logger:               slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)),
+	}
+	original := testInstance.newListener(unreachable)
+	testInstance.listener = original
+
+	// nothing has changed, so the listener is left to carry on trying
+	assert.Nil(t, testInstance.startOver(ctx))
+	currentErr = errors.New("no credentials for you")
+	current = strings.Replace(unreachable, "first", "second", 1)
+	assert.Nil(t, testInstance.startOver(ctx))
+	assert.Same(t, original, testInstance.listener)
+
+	// rotated credentials have the listener start over with them
+	currentErr = nil
+	replacement := testInstance.startOver(ctx)
+	require.NotNil(t, replacement)
+	assert.Same(t, replacement, testInstance.listener)
+	assert.Equal(t, current, testInstance.dataSourceName)
+	assert.Error(t, original.Close(), "the original listener should already be closed")
+	assert.NoError(t, replacement.Close())
+}
+
+func TestInvalidationListener_Postgres_CredentialRotation(t *testing.T) {
+	ctx := context.Background()
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+	conn, err := connectionOpener.OpenConnection()
+	require.NoError(t, err)
+
+	role := fmt.Sprintf("creature_test_%s", strings.ReplaceAll(uuid.NewString(), "-", ""))
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("create role %s login password 'first'", role))
+	require.NoError(t, err)
+	defer func() {
+		_, err := conn.ExecContext(ctx, fmt.Sprintf("drop role %s", role))
+		assert.NoError(t, err)
+	}()
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("first\n"), 0o600))
+	rotatingCfg := connectionCfg
+	rotatingCfg.User = role
+	rotatingCfg.Password = ""
+	rotatingCfg.PasswordFile = passwordFile
+	rotatingOpener := db.NewConnectionOpener(rotatingCfg)
+	dataSourceName, err := rotatingOpener.CurrentDataSourceName(ctx)
+	require.NoError(t, err)
+
+	channel := fmt.Sprintf("creature_test_%s", uuid.NewString())
+	listener, err := NewInvalidationListener(ctx, dataSourceName, InvalidationListenerOptions{
+		Channel:               channel,
+		MinReconnectInterval:  10 * time.Millisecond,
+		MaxReconnectInterval:  100 * time.Millisecond,
+		CurrentDataSourceName: rotatingOpener.CurrentDataSourceName,
+	})
+	require.NoError(t, err)
+	defer listener.Close()
+	invalidated := make(chan int64, 10)
+	listener.Subscribe(invalidatorFunc(func(id int64) {
+		invalidated <- id
+	}))
+
+	// rotate the password, then kill the listener's connection so that it has to reconnect with the new one
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("alter role %s password 'second'", role))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(passwordFile, []byte("second\n"), 0o600))
+	_, err = conn.ExecContext(ctx, "select pg_terminate_backend(pid) from pg_stat_activity where usename = $1", role)
+	require.NoError(t, err)
+
+	// starting over means everything goes, as there is no knowing what was missed
+	select {
+	case id := <-invalidated:
+		assert.Equal(t, int64(-1), id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener never reconnected")
+	}
+	_, err = conn.ExecContext(ctx, "select pg_notify($1, '42')", channel)
+	require.NoError(t, err)
+	select {
+	case id := <-invalidated:
+		assert.Equal(t, int64(42), id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification never arrived")
+	}
+}
+
+// invalidatorFunc reports the ids it is asked to invalidate, with -1 standing in for everything
+type invalidatorFunc func(id int64)
+
+func (f invalidatorFunc) Invalidate(_ context.Context, id int64) {
+	f(id)
+}
+
+func (f invalidatorFunc) InvalidateAll(_ context.Context) {
+	f(-1)
+}
+
+func TestInvalidationListener_Postgres(t *testing.T) {
+	ctx := context.Background()
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+	conn, err := connectionOpener.OpenConnection()
+	require.NoError(t, err)
+
+	name := fmt.Sprintf("creature_test_%s", uuid.NewString())
+	id := insertTestCreature(ctx, t, conn, name, "a creature for testing purposes")
+	defer deleteTestCreature(ctx, t, conn, id)
+
+	// the raw repo stands in for whichever process ends up making changes
+	rawRepo := NewCreatureRepo(connectionOpener)
+	testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+
+	listener, err := NewInvalidationListener(ctx, connectionOpener.DataSourceName(), InvalidationListenerOptions{
+		MinReconnectInterval: 10 * time.Millisecond,
+		MaxReconnectInterval: 100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer listener.Close()
+	listener.Subscribe(testInstance)
+
+	result, err := testInstance.GetCreature(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "a creature for testing purposes", result.Creature.Description)
+
+	description := "a creature changed behind the cache's back"
+	_, err = rawRepo.UpdateCreature(ctx, id, CreatureUpdate{Description: &description})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		result, err := testInstance.GetCreature(ctx, id)
+		return err == nil && result.Creature.Description == description
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// deleting should be noticed too
+	_, err = rawRepo.DeleteCreature(ctx, id)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		result, err := testInstance.GetCreature(ctx, id)
+		return err == nil && !result.ResultFound
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestInvalidationListener_Postgres_Reconnect(t *testing.T) {
+	ctx := context.Background()
+
+	connectionCfg, err := db.ConnectionParamsFromEnv()
+	require.NoError(t, err)
+	connectionOpener := db.NewConnectionOpener(connectionCfg)
+	defer connectionOpener.Close()
+	conn, err := connectionOpener.OpenConnection()
+	require.NoError(t, err)
+
+	name := fmt.Sprintf("creature_test_%s", uuid.NewString())
+	id := insertTestCreature(ctx, t, conn, name, "a creature for testing purposes")
+	defer deleteTestCreature(ctx, t, conn, id)
+
+	channel := fmt.Sprintf("creature_test_%s", uuid.NewString())
+	listener, err := NewInvalidationListener(ctx, connectionOpener.DataSourceName(), InvalidationListenerOptions{
+		Channel:              channel,
+		MinReconnectInterval: 10 * time.Millisecond,
+		MaxReconnectInterval: 100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, id).Return(CreatureLookupResult{ResultFound: true, Creature: Creature{ID: id, Name: name}}, nil).Once()
+	testInstance := NewCachingCreatureRepo(rawRepo, time.Hour)
+	listener.Subscribe(testInstance)
+	_, err = testInstance.GetCreature(ctx, id)
+	require.NoError(t, err)
+
+	// kill the listener's connection, anything could happen before it gets back so everything cached should go
+	_, err = conn.ExecContext(ctx, "select pg_terminate_backend(pid) from pg_stat_activity where query = $1", fmt.Sprintf("LISTEN %q", channel))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return testInstance.Stats().Size == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,51 @@
+package srp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+var ErrInvalidCursor = fmt.Errorf("%w: invalid list cursor", ErrValidation)
+
+// listCursor captures the sort key of the last row of a page so the next page can pick up after it. It is handed to
+// callers as an opaque string.
+type listCursor struct {
+	OrderBy ListOrder `json:"o"`
+	ID      int64     `json:"i"`
+	Name    string    `json:"n,omitempty"`
+}
+
+func newListCursor(orderBy ListOrder, last Creature) listCursor {
+	ret := listCursor{
+		OrderBy: orderBy,
+		ID:      last.ID,
+	}
+	if orderBy == OrderByName {
+		ret.Name = last.Name
+	}
+	return ret
+}
+
+func (l listCursor) encode() string {
+	// marshalling a struct of plain fields can't fail
+	raw, _ := json.Marshal(l)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeListCursor(cursor string, orderBy ListOrder) (listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return listCursor{}, ErrInvalidCursor
+	}
+	var ret listCursor
+	err = json.Unmarshal(raw, &ret)
+	if err != nil {
+		return listCursor{}, ErrInvalidCursor
+	}
+	// a cursor only makes sense for the ordering it was produced under
+	if ret.OrderBy != orderBy {
+		return listCursor{}, ErrInvalidCursor
+	}
+	return ret, nil
+}
@@ -0,0 +1,83 @@
+package srp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListCursor_RoundTrip(t *testing.T) {
+	testCases := []struct {
+		name     string
+		orderBy  ListOrder
+		last     Creature
+		expected listCursor
+	}{
+		{
+			name:    "order by id",
+			orderBy: OrderByID,
+			last: Creature{
+				ID:          123,
+				Name:        "bob",
+				Description: "likes testing",
+			},
+			expected: listCursor{
+				OrderBy: OrderByID,
+				ID:      123,
+			},
+		},
+		{
+			name:    "order by name",
+			orderBy: OrderByName,
+			last: Creature{
+				ID:          123,
+				Name:        "bob",
+				Description: "likes testing",
+			},
+			expected: listCursor{
+				OrderBy: OrderByName,
+				ID:      123,
+				Name:    "bob",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded := newListCursor(tc.orderBy, tc.last).encode()
+			decoded, err := decodeListCursor(encoded, tc.orderBy)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, decoded)
+		})
+	}
+}
+
+func TestDecodeListCursor_Invalid(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cursor  string
+		orderBy ListOrder
+	}{
+		{
+			name:    "not base64",
+			cursor:  "!!!",
+			orderBy: OrderByID,
+		},
+		{
+			name:    "not json",
+			cursor:  "Ym9i",
+			orderBy: OrderByID,
+		},
+		{
+			name:    "different ordering",
+			cursor:  newListCursor(OrderByName, Creature{ID: 1, Name: "bob"}).encode(),
+			orderBy: OrderByID,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := decodeListCursor(tc.cursor, tc.orderBy)
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+		})
+	}
+}
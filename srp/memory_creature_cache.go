@@ -0,0 +1,213 @@
+package srp
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type memoryCacheEntry struct {
+	entry    CreatureCacheEntry
+	deadline time.Time
+}
+
+type MemoryCreatureCacheOptions struct {
+	// MaxEntries bounds the number of cached entries, with EvictionPolicy deciding what goes when the cache is full.
+	// Zero leaves the cache unbounded.
+	MaxEntries     int
+	EvictionPolicy EvictionPolicy
+	// JanitorInterval controls how often a background goroutine sweeps expired entries out of the cache, zero disables
+	// sweeping. Caches with a janitor need to be closed once they are no longer needed.
+	JanitorInterval time.Duration
+	// Clock is used for all expiry decisions, nil means the system clock. Clocks implementing TickerClock decide when
+	// the janitor sweeps as well.
+	Clock Clock
+}
+
+// MemoryCreatureCache is a process local CreatureCache
+type MemoryCreatureCache struct {
+	clock          Clock
+	maxEntries     int
+	evictionPolicy EvictionPolicy
+
+	entries map[int64]memoryCacheEntry
+	// entriesMutex guards entries
+	entriesMutex sync.RWMutex
+	// tracker is only set for bounded caches. It gets updated on cache hits, which only hold the read lock, so it has a
+	// mutex of its own.
+	tracker      evictionTracker
+	trackerMutex sync.Mutex
+
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+
+	janitorStop chan struct{}
+	janitorDone chan struct{}
+	closeOnce   sync.Once
+}
+
+func NewMemoryCreatureCache(options MemoryCreatureCacheOptions) *MemoryCreatureCache {
+	clock := options.Clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	ret := &MemoryCreatureCache{
+		clock:          clock,
+		maxEntries:     options.MaxEntries,
+		evictionPolicy: options.EvictionPolicy,
+		entries:        make(map[int64]memoryCacheEntry),
+	}
+	if options.MaxEntries > 0 {
+		ret.tracker = newEvictionTracker(options.EvictionPolicy)
+	}
+	if options.JanitorInterval > 0 {
+		ret.janitorStop = make(chan struct{})
+		ret.janitorDone = make(chan struct{})
+		// the ticker is made up front, so that anything driving it through the clock can count on it from here on
+		ticks, stop := newTicker(clock, options.JanitorInterval)
+		go ret.runJanitor(ticks, stop)
+	}
+	return ret
+}
+
+// Close stops the background janitor, if there is one. The cache remains usable afterward.
+func (m *MemoryCreatureCache) Close() error {
+	m.closeOnce.Do(func() {
+		if m.janitorStop != nil {
+			close(m.janitorStop)
+			<-m.janitorDone
+		}
+	})
+	return nil
+}
+
+// Stats reports on the size of the cache and its housekeeping, the remaining fields are left for CachingCreatureRepo
+// to fill in
+func (m *MemoryCreatureCache) Stats() CacheStats {
+	m.entriesMutex.RLock()
+	size := len(m.entries)
+	m.entriesMutex.RUnlock()
+	return CacheStats{
+		Size:        size,
+		Evictions:   m.evictions.Load(),
+		Expirations: m.expirations.Load(),
+	}
+}
+
+// Entries returns a copy of every entry whose ttl has yet to pass
+func (m *MemoryCreatureCache) Entries() map[int64]CreatureCacheEntry {
+	m.entriesMutex.RLock()
+	defer m.entriesMutex.RUnlock()
+	now := m.clock.Now()
+	ret := make(map[int64]CreatureCacheEntry, len(m.entries))
+	for id, cached := range m.entries {
+		if !now.After(cached.deadline) {
+			ret[id] = cached.entry
+		}
+	}
+	return ret
+}
+
+func (m *MemoryCreatureCache) Get(_ context.Context, id int64) (CreatureCacheEntry, bool, error) {
+	m.entriesMutex.RLock()
+	defer m.entriesMutex.RUnlock()
+	cached, found := m.entries[id]
+	if !found || m.clock.Now().After(cached.deadline) {
+		return CreatureCacheEntry{}, false, nil
+	}
+	m.touch(id)
+	return cached.entry, true, nil
+}
+
+func (m *MemoryCreatureCache) Set(_ context.Context, id int64, entry CreatureCacheEntry, ttl time.Duration) error {
+	m.entriesMutex.Lock()
+	defer m.entriesMutex.Unlock()
+	if _, cached := m.entries[id]; !cached && m.maxEntries > 0 && len(m.entries) >= m.maxEntries {
+		m.evictLocked()
+	}
+	m.entries[id] = memoryCacheEntry{
+		entry:    entry,
+		deadline: m.clock.Now().Add(ttl),
+	}
+	if m.tracker != nil {
+		m.trackerMutex.Lock()
+		m.tracker.add(id)
+		m.trackerMutex.Unlock()
+	}
+	return nil
+}
+
+func (m *MemoryCreatureCache) Delete(_ context.Context, id int64) error {
+	m.entriesMutex.Lock()
+	defer m.entriesMutex.Unlock()
+	m.removeLocked(id)
+	return nil
+}
+
+func (m *MemoryCreatureCache) Clear(_ context.Context) error {
+	m.entriesMutex.Lock()
+	defer m.entriesMutex.Unlock()
+	m.entries = make(map[int64]memoryCacheEntry)
+	if m.tracker != nil {
+		m.trackerMutex.Lock()
+		m.tracker = newEvictionTracker(m.evictionPolicy)
+		m.trackerMutex.Unlock()
+	}
+	return nil
+}
+
+func (m *MemoryCreatureCache) removeLocked(id int64) {
+	delete(m.entries, id)
+	if m.tracker != nil {
+		m.trackerMutex.Lock()
+		m.tracker.remove(id)
+		m.trackerMutex.Unlock()
+	}
+}
+
+func (m *MemoryCreatureCache) evictLocked() {
+	m.trackerMutex.Lock()
+	victim, found := m.tracker.victim()
+	m.trackerMutex.Unlock()
+	if !found {
+		return
+	}
+	m.removeLocked(victim)
+	m.evictions.Add(1)
+}
+
+// touch records a cache hit for eviction purposes, callers must hold at least the read lock
+func (m *MemoryCreatureCache) touch(id int64) {
+	if m.tracker == nil {
+		return
+	}
+	m.trackerMutex.Lock()
+	m.tracker.touch(id)
+	m.trackerMutex.Unlock()
+}
+
+func (m *MemoryCreatureCache) runJanitor(ticks <-chan time.Time, stop func()) {
+	defer close(m.janitorDone)
+	defer stop()
+	for {
+		select {
+		case <-ticks:
+			m.sweepExpired()
+		case <-m.janitorStop:
+			return
+		}
+	}
+}
+
+func (m *MemoryCreatureCache) sweepExpired() {
+	m.entriesMutex.Lock()
+	defer m.entriesMutex.Unlock()
+	now := m.clock.Now()
+	for id, cached := range m.entries {
+		if now.After(cached.deadline) {
+			m.removeLocked(id)
+			m.expirations.Add(1)
+		}
+	}
+}
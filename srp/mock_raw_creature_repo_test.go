@@ -79,6 +79,122 @@ func (_c *MockRawCreatureRepo_CreateCreature_Call) RunAndReturn(run func(context
 	return _c
 }
 
+// CreateCreatures provides a mock function with given fields: ctx, creatures
+func (_m *MockRawCreatureRepo) CreateCreatures(ctx context.Context, creatures []Creature) ([]Creature, error) {
+	ret := _m.Called(ctx, creatures)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateCreatures")
+	}
+
+	var r0 []Creature
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []Creature) ([]Creature, error)); ok {
+		return rf(ctx, creatures)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []Creature) []Creature); ok {
+		r0 = rf(ctx, creatures)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]Creature)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []Creature) error); ok {
+		r1 = rf(ctx, creatures)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRawCreatureRepo_CreateCreatures_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateCreatures'
+type MockRawCreatureRepo_CreateCreatures_Call struct {
+	*mock.Call
+}
+
+// CreateCreatures is a helper method to define mock.On call
+//   - ctx context.Context
+//   - creatures []Creature
+func (_e *MockRawCreatureRepo_Expecter) CreateCreatures(ctx interface{}, creatures interface{}) *MockRawCreatureRepo_CreateCreatures_Call {
+	return &MockRawCreatureRepo_CreateCreatures_Call{Call: _e.mock.On("CreateCreatures", ctx, creatures)}
+}
+
+func (_c *MockRawCreatureRepo_CreateCreatures_Call) Run(run func(ctx context.Context, creatures []Creature)) *MockRawCreatureRepo_CreateCreatures_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]Creature))
+	})
+	return _c
+}
+
+func (_c *MockRawCreatureRepo_CreateCreatures_Call) Return(_a0 []Creature, _a1 error) *MockRawCreatureRepo_CreateCreatures_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRawCreatureRepo_CreateCreatures_Call) RunAndReturn(run func(context.Context, []Creature) ([]Creature, error)) *MockRawCreatureRepo_CreateCreatures_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteCreature provides a mock function with given fields: ctx, id
+func (_m *MockRawCreatureRepo) DeleteCreature(ctx context.Context, id int64) (CreatureDeleteResult, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteCreature")
+	}
+
+	var r0 CreatureDeleteResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (CreatureDeleteResult, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) CreatureDeleteResult); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(CreatureDeleteResult)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRawCreatureRepo_DeleteCreature_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteCreature'
+type MockRawCreatureRepo_DeleteCreature_Call struct {
+	*mock.Call
+}
+
+// DeleteCreature is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id int64
+func (_e *MockRawCreatureRepo_Expecter) DeleteCreature(ctx interface{}, id interface{}) *MockRawCreatureRepo_DeleteCreature_Call {
+	return &MockRawCreatureRepo_DeleteCreature_Call{Call: _e.mock.On("DeleteCreature", ctx, id)}
+}
+
+func (_c *MockRawCreatureRepo_DeleteCreature_Call) Run(run func(ctx context.Context, id int64)) *MockRawCreatureRepo_DeleteCreature_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int64))
+	})
+	return _c
+}
+
+func (_c *MockRawCreatureRepo_DeleteCreature_Call) Return(_a0 CreatureDeleteResult, _a1 error) *MockRawCreatureRepo_DeleteCreature_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRawCreatureRepo_DeleteCreature_Call) RunAndReturn(run func(context.Context, int64) (CreatureDeleteResult, error)) *MockRawCreatureRepo_DeleteCreature_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetCreature provides a mock function with given fields: ctx, id
 func (_m *MockRawCreatureRepo) GetCreature(ctx context.Context, id int64) (CreatureLookupResult, error) {
 	ret := _m.Called(ctx, id)
@@ -136,6 +252,237 @@ func (_c *MockRawCreatureRepo_GetCreature_Call) RunAndReturn(run func(context.Co
 	return _c
 }
 
+// GetCreatureByName provides a mock function with given fields: ctx, name
+func (_m *MockRawCreatureRepo) GetCreatureByName(ctx context.Context, name string) (CreatureLookupResult, error) {
+	ret := _m.Called(ctx, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCreatureByName")
+	}
+
+	var r0 CreatureLookupResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (CreatureLookupResult, error)); ok {
+		return rf(ctx, name)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) CreatureLookupResult); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Get(0).(CreatureLookupResult)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRawCreatureRepo_GetCreatureByName_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetCreatureByName'
+type MockRawCreatureRepo_GetCreatureByName_Call struct {
+	*mock.Call
+}
+
+// GetCreatureByName is a helper method to define mock.On call
+//   - ctx context.Context
+//   - name string
+func (_e *MockRawCreatureRepo_Expecter) GetCreatureByName(ctx interface{}, name interface{}) *MockRawCreatureRepo_GetCreatureByName_Call {
+	return &MockRawCreatureRepo_GetCreatureByName_Call{Call: _e.mock.On("GetCreatureByName", ctx, name)}
+}
+
+func (_c *MockRawCreatureRepo_GetCreatureByName_Call) Run(run func(ctx context.Context, name string)) *MockRawCreatureRepo_GetCreatureByName_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockRawCreatureRepo_GetCreatureByName_Call) Return(_a0 CreatureLookupResult, _a1 error) *MockRawCreatureRepo_GetCreatureByName_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRawCreatureRepo_GetCreatureByName_Call) RunAndReturn(run func(context.Context, string) (CreatureLookupResult, error)) *MockRawCreatureRepo_GetCreatureByName_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetCreatures provides a mock function with given fields: ctx, ids
+func (_m *MockRawCreatureRepo) GetCreatures(ctx context.Context, ids []int64) (map[int64]CreatureLookupResult, error) {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCreatures")
+	}
+
+	var r0 map[int64]CreatureLookupResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) (map[int64]CreatureLookupResult, error)); ok {
+		return rf(ctx, ids)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) map[int64]CreatureLookupResult); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[int64]CreatureLookupResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int64) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRawCreatureRepo_GetCreatures_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetCreatures'
+type MockRawCreatureRepo_GetCreatures_Call struct {
+	*mock.Call
+}
+
+// GetCreatures is a helper method to define mock.On call
+//   - ctx context.Context
+//   - ids []int64
+func (_e *MockRawCreatureRepo_Expecter) GetCreatures(ctx interface{}, ids interface{}) *MockRawCreatureRepo_GetCreatures_Call {
+	return &MockRawCreatureRepo_GetCreatures_Call{Call: _e.mock.On("GetCreatures", ctx, ids)}
+}
+
+func (_c *MockRawCreatureRepo_GetCreatures_Call) Run(run func(ctx context.Context, ids []int64)) *MockRawCreatureRepo_GetCreatures_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]int64))
+	})
+	return _c
+}
+
+func (_c *MockRawCreatureRepo_GetCreatures_Call) Return(_a0 map[int64]CreatureLookupResult, _a1 error) *MockRawCreatureRepo_GetCreatures_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRawCreatureRepo_GetCreatures_Call) RunAndReturn(run func(context.Context, []int64) (map[int64]CreatureLookupResult, error)) *MockRawCreatureRepo_GetCreatures_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListCreatures provides a mock function with given fields: ctx, options
+func (_m *MockRawCreatureRepo) ListCreatures(ctx context.Context, options ListOptions) (CreaturePage, error) {
+	ret := _m.Called(ctx, options)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListCreatures")
+	}
+
+	var r0 CreaturePage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, ListOptions) (CreaturePage, error)); ok {
+		return rf(ctx, options)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, ListOptions) CreaturePage); ok {
+		r0 = rf(ctx, options)
+	} else {
+		r0 = ret.Get(0).(CreaturePage)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, ListOptions) error); ok {
+		r1 = rf(ctx, options)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRawCreatureRepo_ListCreatures_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListCreatures'
+type MockRawCreatureRepo_ListCreatures_Call struct {
+	*mock.Call
+}
+
+// ListCreatures is a helper method to define mock.On call
+//   - ctx context.Context
+//   - options ListOptions
+func (_e *MockRawCreatureRepo_Expecter) ListCreatures(ctx interface{}, options interface{}) *MockRawCreatureRepo_ListCreatures_Call {
+	return &MockRawCreatureRepo_ListCreatures_Call{Call: _e.mock.On("ListCreatures", ctx, options)}
+}
+
+func (_c *MockRawCreatureRepo_ListCreatures_Call) Run(run func(ctx context.Context, options ListOptions)) *MockRawCreatureRepo_ListCreatures_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(ListOptions))
+	})
+	return _c
+}
+
+func (_c *MockRawCreatureRepo_ListCreatures_Call) Return(_a0 CreaturePage, _a1 error) *MockRawCreatureRepo_ListCreatures_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRawCreatureRepo_ListCreatures_Call) RunAndReturn(run func(context.Context, ListOptions) (CreaturePage, error)) *MockRawCreatureRepo_ListCreatures_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdateCreature provides a mock function with given fields: ctx, id, update
+func (_m *MockRawCreatureRepo) UpdateCreature(ctx context.Context, id int64, update CreatureUpdate) (CreatureUpdateResult, error) {
+	ret := _m.Called(ctx, id, update)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateCreature")
+	}
+
+	var r0 CreatureUpdateResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, CreatureUpdate) (CreatureUpdateResult, error)); ok {
+		return rf(ctx, id, update)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, CreatureUpdate) CreatureUpdateResult); ok {
+		r0 = rf(ctx, id, update)
+	} else {
+		r0 = ret.Get(0).(CreatureUpdateResult)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, CreatureUpdate) error); ok {
+		r1 = rf(ctx, id, update)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRawCreatureRepo_UpdateCreature_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateCreature'
+type MockRawCreatureRepo_UpdateCreature_Call struct {
+	*mock.Call
+}
+
+// UpdateCreature is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id int64
+//   - update CreatureUpdate
+func (_e *MockRawCreatureRepo_Expecter) UpdateCreature(ctx interface{}, id interface{}, update interface{}) *MockRawCreatureRepo_UpdateCreature_Call {
+	return &MockRawCreatureRepo_UpdateCreature_Call{Call: _e.mock.On("UpdateCreature", ctx, id, update)}
+}
+
+func (_c *MockRawCreatureRepo_UpdateCreature_Call) Run(run func(ctx context.Context, id int64, update CreatureUpdate)) *MockRawCreatureRepo_UpdateCreature_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int64), args[2].(CreatureUpdate))
+	})
+	return _c
+}
+
+func (_c *MockRawCreatureRepo_UpdateCreature_Call) Return(_a0 CreatureUpdateResult, _a1 error) *MockRawCreatureRepo_UpdateCreature_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRawCreatureRepo_UpdateCreature_Call) RunAndReturn(run func(context.Context, int64, CreatureUpdate) (CreatureUpdateResult, error)) *MockRawCreatureRepo_UpdateCreature_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockRawCreatureRepo creates a new instance of MockRawCreatureRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockRawCreatureRepo(t interface {
@@ -0,0 +1,91 @@
+package srp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const DefaultRedisKeyPrefix = "srp:creature:"
+
+// clearBatchSize is how many keys Clear asks redis for at a time
+const clearBatchSize = 500
+
+// RedisCreatureCache is a CreatureCache that can be shared between processes. Entries are stored as JSON under the key
+// prefix followed by the creature id, and expire by way of redis key expiry.
+type RedisCreatureCache struct {
+	client    redis.UniversalClient
+	keyPrefix string
+}
+
+// NewRedisCreatureCache creates a cache on top of client, which remains owned by the caller. An empty keyPrefix means
+// DefaultRedisKeyPrefix.
+func NewRedisCreatureCache(client redis.UniversalClient, keyPrefix string) *RedisCreatureCache {
+	if keyPrefix == "" {
+		keyPrefix = DefaultRedisKeyPrefix
+	}
+	return &RedisCreatureCache{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+func (r *RedisCreatureCache) Get(ctx context.Context, id int64) (CreatureCacheEntry, bool, error) {
+	raw, err := r.client.Get(ctx, r.key(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return CreatureCacheEntry{}, false, nil
+	}
+	if err != nil {
+		return CreatureCacheEntry{}, false, err
+	}
+	var ret CreatureCacheEntry
+	if err := json.Unmarshal(raw, &ret); err != nil {
+		return CreatureCacheEntry{}, false, err
+	}
+	return ret, true, nil
+}
+
+func (r *RedisCreatureCache) Set(ctx context.Context, id int64, entry CreatureCacheEntry, ttl time.Duration) error {
+	if ttl <= 0 {
+		// redis treats a zero expiry as never expiring, which is the opposite of what was asked for
+		return r.Delete(ctx, id)
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, r.key(id), raw, ttl).Err()
+}
+
+func (r *RedisCreatureCache) Delete(ctx context.Context, id int64) error {
+	return r.client.Del(ctx, r.key(id)).Err()
+}
+
+// Clear removes every key under the key prefix. Keys are found with SCAN, so entries written while a clear is running
+// may or may not survive it.
+func (r *RedisCreatureCache) Clear(ctx context.Context) error {
+	var cursor uint64
+	for {
+		keys, next, err := r.client.Scan(ctx, cursor, r.keyPrefix+"*", clearBatchSize).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := r.client.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+func (r *RedisCreatureCache) key(id int64) string {
+	return r.keyPrefix + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,93 @@
+package srp
+
+import (
+	"context"
+	"time"
+)
+
+// ShardedMemoryCreatureCache spreads entries across a number of MemoryCreatureCache shards, each with its own lock, so
+// that lookups of different ids contend less with each other
+type ShardedMemoryCreatureCache struct {
+	shards []*MemoryCreatureCache
+}
+
+// NewShardedMemoryCreatureCache creates a cache with the given number of shards, each configured with options. When
+// the cache is bounded MaxEntries is split evenly between the shards, rounding up.
+func NewShardedMemoryCreatureCache(shards int, options MemoryCreatureCacheOptions) *ShardedMemoryCreatureCache {
+	if shards < 1 {
+		shards = 1
+	}
+	if options.MaxEntries > 0 {
+		options.MaxEntries = (options.MaxEntries + shards - 1) / shards
+	}
+	ret := &ShardedMemoryCreatureCache{
+		shards: make([]*MemoryCreatureCache, shards),
+	}
+	for i := range ret.shards {
+		ret.shards[i] = NewMemoryCreatureCache(options)
+	}
+	return ret
+}
+
+// Close stops the janitors of all shards
+func (s *ShardedMemoryCreatureCache) Close() error {
+	for _, shard := range s.shards {
+		if err := shard.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ShardedMemoryCreatureCache) Stats() CacheStats {
+	var ret CacheStats
+	for _, shard := range s.shards {
+		stats := shard.Stats()
+		ret.Size += stats.Size
+		ret.Evictions += stats.Evictions
+		ret.Expirations += stats.Expirations
+	}
+	return ret
+}
+
+func (s *ShardedMemoryCreatureCache) Entries() map[int64]CreatureCacheEntry {
+	ret := make(map[int64]CreatureCacheEntry)
+	for _, shard := range s.shards {
+		for id, entry := range shard.Entries() {
+			ret[id] = entry
+		}
+	}
+	return ret
+}
+
+func (s *ShardedMemoryCreatureCache) Get(ctx context.Context, id int64) (CreatureCacheEntry, bool, error) {
+	return s.shard(id).Get(ctx, id)
+}
+
+func (s *ShardedMemoryCreatureCache) Set(ctx context.Context, id int64, entry CreatureCacheEntry, ttl time.Duration) error {
+	return s.shard(id).Set(ctx, id, entry, ttl)
+}
+
+func (s *ShardedMemoryCreatureCache) Delete(ctx context.Context, id int64) error {
+	return s.shard(id).Delete(ctx, id)
+}
+
+func (s *ShardedMemoryCreatureCache) Clear(ctx context.Context) error {
+	for _, shard := range s.shards {
+		if err := shard.Clear(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ShardedMemoryCreatureCache) shard(id int64) *MemoryCreatureCache {
+	return s.shards[shardIndex(id, len(s.shards))]
+}
+
+// shardIndex picks the shard for an id. Ids are mixed with a fibonacci hash first so that ids following a pattern, such
+// as only even ids being hot, still spread across all the shards.
+func shardIndex(id int64, shards int) int {
+	hash := uint64(id) * 0x9e3779b97f4a7c15
+	return int((hash >> 32) % uint64(shards))
+}
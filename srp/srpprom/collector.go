@@ -0,0 +1,112 @@
+// Package srpprom exports the statistics of srp caches as Prometheus metrics. It lives apart from srp so that only
+// programs making use of it pull in the Prometheus client.
+package srpprom
+
+import (
+	"github.com/jonsabados/srp-sample/srp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StatsReporter is anything able to report cache statistics, such as srp.CachingCreatureRepo
+type StatsReporter interface {
+	Stats() srp.CacheStats
+}
+
+type CacheCollectorOptions struct {
+	// Namespace prefixes every metric name, empty means "srp"
+	Namespace string
+	// ConstLabels are attached to every metric, for telling apart caches registered with the same registry
+	ConstLabels prometheus.Labels
+}
+
+// CacheCollector is a prometheus.Collector reading the statistics of a cache each time metrics are collected. It is
+// left to the caller to register it. Average load latency can be had by dividing the rate of load_seconds_total by that
+// of loads_total.
+type CacheCollector struct {
+	reporter StatsReporter
+	metrics  []cacheMetric
+}
+
+// cacheMetric pairs a metric description with how to read its value out of the stats
+type cacheMetric struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	value     func(stats srp.CacheStats) float64
+}
+
+func NewCacheCollector(reporter StatsReporter, options CacheCollectorOptions) *CacheCollector {
+	namespace := options.Namespace
+	if namespace == "" {
+		namespace = "srp"
+	}
+	metric := func(name, help string, valueType prometheus.ValueType, value func(stats srp.CacheStats) float64) cacheMetric {
+		return cacheMetric{
+			desc:      prometheus.NewDesc(prometheus.BuildFQName(namespace, "creature_cache", name), help, nil, options.ConstLabels),
+			valueType: valueType,
+			value:     value,
+		}
+	}
+	return &CacheCollector{
+		reporter: reporter,
+		metrics: []cacheMetric{
+			metric("entries", "Number of entries currently held by the cache.", prometheus.GaugeValue, func(stats srp.CacheStats) float64 {
+				return float64(stats.Size)
+			}),
+			metric("hits_total", "Lookups served from cache.", prometheus.CounterValue, func(stats srp.CacheStats) float64 {
+				return float64(stats.Hits)
+			}),
+			metric("negative_hits_total", "Lookups served from cached not-found results.", prometheus.CounterValue, func(stats srp.CacheStats) float64 {
+				return float64(stats.NegativeHits)
+			}),
+			metric("stale_hits_total", "Lookups served from expired entries within their stale window.", prometheus.CounterValue, func(stats srp.CacheStats) float64 {
+				return float64(stats.StaleHits)
+			}),
+			metric("refresh_ahead_hits_total", "Lookups served from entries due to be refreshed ahead of expiry.", prometheus.CounterValue, func(stats srp.CacheStats) float64 {
+				return float64(stats.RefreshAheadHits)
+			}),
+			metric("misses_total", "Lookups that had to wait on the database.", prometheus.CounterValue, func(stats srp.CacheStats) float64 {
+				return float64(stats.Misses)
+			}),
+			metric("loads_total", "Lookups made against the database.", prometheus.CounterValue, func(stats srp.CacheStats) float64 {
+				return float64(stats.Loads)
+			}),
+			metric("load_errors_total", "Lookups made against the database that failed.", prometheus.CounterValue, func(stats srp.CacheStats) float64 {
+				return float64(stats.LoadErrors)
+			}),
+			metric("load_seconds_total", "Total time spent on lookups made against the database.", prometheus.CounterValue, func(stats srp.CacheStats) float64 {
+				return stats.LoadTime.Seconds()
+			}),
+			metric("refresh_errors_total", "Background refreshes that failed.", prometheus.CounterValue, func(stats srp.CacheStats) float64 {
+				return float64(stats.RefreshErrors)
+			}),
+			metric("store_errors_total", "Failed reads and writes of the store backing the cache.", prometheus.CounterValue, func(stats srp.CacheStats) float64 {
+				return float64(stats.CacheErrors)
+			}),
+			metric("pending_writes", "Creates queued to be written behind that have yet to be made.", prometheus.GaugeValue, func(stats srp.CacheStats) float64 {
+				return float64(stats.PendingWrites)
+			}),
+			metric("write_errors_total", "Creates queued to be written behind that failed once made.", prometheus.CounterValue, func(stats srp.CacheStats) float64 {
+				return float64(stats.WriteErrors)
+			}),
+			metric("evictions_total", "Entries thrown away to make room for new ones.", prometheus.CounterValue, func(stats srp.CacheStats) float64 {
+				return float64(stats.Evictions)
+			}),
+			metric("expirations_total", "Expired entries swept out of the cache.", prometheus.CounterValue, func(stats srp.CacheStats) float64 {
+				return float64(stats.Expirations)
+			}),
+		},
+	}
+}
+
+func (c *CacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, metric := range c.metrics {
+		ch <- metric.desc
+	}
+}
+
+func (c *CacheCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.reporter.Stats()
+	for _, metric := range c.metrics {
+		ch <- prometheus.MustNewConstMetric(metric.desc, metric.valueType, metric.value(stats))
+	}
+}
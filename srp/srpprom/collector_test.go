@@ -0,0 +1,133 @@
+package srpprom
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonsabados/srp-sample/srp"
+	"github.com/jonsabados/srp-sample/srp/srptest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubRawCreatureRepo finds creatures with even ids, fails on negative ids, and takes latency on the clock to do so
+type stubRawCreatureRepo struct {
+	srp.RawCreatureRepo
+	clock   *srptest.ManualClock
+	latency time.Duration
+}
+
+func (s *stubRawCreatureRepo) GetCreature(ctx context.Context, id int64) (srp.CreatureLookupResult, error) {
+	s.clock.Advance(s.latency)
+	if id < 0 {
+		return srp.CreatureLookupResult{}, errors.New("some DB error here")
+	}
+	if id%2 != 0 {
+		return srp.CreatureLookupResult{ResultFound: false}, nil
+	}
+	return srp.CreatureLookupResult{
+		ResultFound: true,
+		Creature:    srp.Creature{ID: id, Name: "bob"},
+	}, nil
+}
+
+func TestCacheCollector(t *testing.T) {
+	ctx := context.Background()
+	clock := srptest.NewManualClock(time.Now())
+	repo := srp.NewCachingCreatureRepoWithOptions(&stubRawCreatureRepo{clock: clock, latency: 250 * time.Millisecond}, srp.CachingCreatureRepoOptions{
+		CacheDuration: time.Hour,
+		MaxEntries:    2,
+		Clock:         clock,
+	})
+	defer repo.Close()
+
+	// two found and one not found creature loaded, with the first found one evicted to make room for the other two
+	for _, id := range []int64{2, 4, 4, 1, 1, 1} {
+		_, err := repo.GetCreature(ctx, id)
+		require.NoError(t, err)
+	}
+	_, err := repo.GetCreature(ctx, -1)
+	require.Error(t, err)
+
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(NewCacheCollector(repo, CacheCollectorOptions{
+		ConstLabels: prometheus.Labels{"cache": "creatures"},
+	})))
+
+	expected := `
+# HELP srp_creature_cache_entries Number of entries currently held by the cache.
+# TYPE srp_creature_cache_entries gauge
+srp_creature_cache_entries{cache="creatures"} 2
+# HELP srp_creature_cache_evictions_total Entries thrown away to make room for new ones.
+# TYPE srp_creature_cache_evictions_total counter
+srp_creature_cache_evictions_total{cache="creatures"} 1
+# HELP srp_creature_cache_expirations_total Expired entries swept out of the cache.
+# TYPE srp_creature_cache_expirations_total counter
+srp_creature_cache_expirations_total{cache="creatures"} 0
+# HELP srp_creature_cache_hits_total Lookups served from cache.
+# TYPE srp_creature_cache_hits_total counter
+srp_creature_cache_hits_total{cache="creatures"} 3
+# HELP srp_creature_cache_load_errors_total Lookups made against the database that failed.
+# TYPE srp_creature_cache_load_errors_total counter
+srp_creature_cache_load_errors_total{cache="creatures"} 1
+# HELP srp_creature_cache_load_seconds_total Total time spent on lookups made against the database.
+# TYPE srp_creature_cache_load_seconds_total counter
+srp_creature_cache_load_seconds_total{cache="creatures"} 1
+# HELP srp_creature_cache_loads_total Lookups made against the database.
+# TYPE srp_creature_cache_loads_total counter
+srp_creature_cache_loads_total{cache="creatures"} 4
+# HELP srp_creature_cache_misses_total Lookups that had to wait on the database.
+# TYPE srp_creature_cache_misses_total counter
+srp_creature_cache_misses_total{cache="creatures"} 4
+# HELP srp_creature_cache_negative_hits_total Lookups served from cached not-found results.
+# TYPE srp_creature_cache_negative_hits_total counter
+srp_creature_cache_negative_hits_total{cache="creatures"} 2
+# HELP srp_creature_cache_refresh_ahead_hits_total Lookups served from entries due to be refreshed ahead of expiry.
+# TYPE srp_creature_cache_refresh_ahead_hits_total counter
+srp_creature_cache_refresh_ahead_hits_total{cache="creatures"} 0
+# HELP srp_creature_cache_refresh_errors_total Background refreshes that failed.
+# TYPE srp_creature_cache_refresh_errors_total counter
+srp_creature_cache_refresh_errors_total{cache="creatures"} 0
+# HELP srp_creature_cache_pending_writes Creates queued to be written behind that have yet to be made.
+# TYPE srp_creature_cache_pending_writes gauge
+srp_creature_cache_pending_writes{cache="creatures"} 0
+# HELP srp_creature_cache_stale_hits_total Lookups served from expired entries within their stale window.
+# TYPE srp_creature_cache_stale_hits_total counter
+srp_creature_cache_stale_hits_total{cache="creatures"} 0
+# HELP srp_creature_cache_store_errors_total Failed reads and writes of the store backing the cache.
+# TYPE srp_creature_cache_store_errors_total counter
+srp_creature_cache_store_errors_total{cache="creatures"} 0
+# HELP srp_creature_cache_write_errors_total Creates queued to be written behind that failed once made.
+# TYPE srp_creature_cache_write_errors_total counter
+srp_creature_cache_write_errors_total{cache="creatures"} 0
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+
+	// metrics are read afresh on every scrape
+	_, err = repo.GetCreature(ctx, 4)
+	require.NoError(t, err)
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP srp_creature_cache_hits_total Lookups served from cache.
+# TYPE srp_creature_cache_hits_total counter
+srp_creature_cache_hits_total{cache="creatures"} 4
+`), "srp_creature_cache_hits_total"))
+}
+
+func TestCacheCollector_Namespace(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	clock := srptest.NewManualClock(time.Now())
+	repo := srp.NewCachingCreatureRepoWithOptions(&stubRawCreatureRepo{clock: clock}, srp.CachingCreatureRepoOptions{
+		CacheDuration: time.Hour,
+		Clock:         clock,
+	})
+	require.NoError(t, registry.Register(NewCacheCollector(repo, CacheCollectorOptions{Namespace: "bestiary"})))
+
+	count, err := testutil.GatherAndCount(registry, "bestiary_creature_cache_hits_total", "bestiary_creature_cache_entries")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
@@ -0,0 +1,103 @@
+// Package srptest provides helpers for testing code built on top of srp
+package srptest
+
+import (
+	"sync"
+	"time"
+)
+
+// ManualClock is a clock (satisfying srp.Clock and srp.TickerClock) that only moves when told to
+type ManualClock struct {
+	now     time.Time
+	tickers []*manualTicker
+	mutex   sync.Mutex
+}
+
+type manualTicker struct {
+	interval time.Duration
+	next     time.Time
+	ticks    chan time.Time
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (m *ManualClock) Now() time.Time {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.now
+}
+
+func (m *ManualClock) Advance(d time.Duration) {
+	m.mutex.Lock()
+	m.now = m.now.Add(d)
+	now, due := m.now, m.dueLocked()
+	m.mutex.Unlock()
+	deliver(now, due)
+}
+
+func (m *ManualClock) Set(now time.Time) {
+	m.mutex.Lock()
+	m.now = now
+	now, due := m.now, m.dueLocked()
+	m.mutex.Unlock()
+	deliver(now, due)
+}
+
+// NewTicker returns ticks that are delivered as the clock is moved past them. Like a time.Ticker, a move spanning more
+// than one interval delivers a single tick. Unlike one, moving the clock waits for each tick to be received, so that
+// once a move returns whatever received the tick of the one before has finished with it, provided that it receives
+// ticks one at a time. Stopping the ticker releases anything waiting on it.
+func (m *ManualClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	if d <= 0 {
+		panic("non-positive interval for ManualClock.NewTicker")
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	ticker := &manualTicker{
+		interval: d,
+		next:     m.now.Add(d),
+		ticks:    make(chan time.Time),
+		stopped:  make(chan struct{}),
+	}
+	m.tickers = append(m.tickers, ticker)
+	return ticker.ticks, func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		for i, candidate := range m.tickers {
+			if candidate == ticker {
+				m.tickers = append(m.tickers[:i], m.tickers[i+1:]...)
+				break
+			}
+		}
+		ticker.stopOnce.Do(func() {
+			close(ticker.stopped)
+		})
+	}
+}
+
+// dueLocked returns the tickers due a tick, moving them on to their next one
+func (m *ManualClock) dueLocked() []*manualTicker {
+	var ret []*manualTicker
+	for _, ticker := range m.tickers {
+		if m.now.Before(ticker.next) {
+			continue
+		}
+		ret = append(ret, ticker)
+		ticker.next = ticker.next.Add((m.now.Sub(ticker.next)/ticker.interval + 1) * ticker.interval)
+	}
+	return ret
+}
+
+// deliver hands out ticks without the clock being locked, so that whatever receives them can tell the time
+func deliver(now time.Time, due []*manualTicker) {
+	for _, ticker := range due {
+		select {
+		case ticker.ticks <- now:
+		case <-ticker.stopped:
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package srptest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2024, 12, 25, 8, 0, 0, 0, time.UTC)
+	testInstance := NewManualClock(start)
+	assert.Equal(t, start, testInstance.Now())
+
+	testInstance.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), testInstance.Now())
+
+	later := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	testInstance.Set(later)
+	assert.Equal(t, later, testInstance.Now())
+}
+
+func TestManualClock_NewTicker(t *testing.T) {
+	start := time.Date(2024, 12, 25, 8, 0, 0, 0, time.UTC)
+	testInstance := NewManualClock(start)
+	ticks, stop := testInstance.NewTicker(time.Minute)
+
+	var received []time.Time
+	receiverDone := make(chan struct{})
+	go func() {
+		defer close(receiverDone)
+		for tick := range ticks {
+			received = append(received, tick)
+			if len(received) == 3 {
+				return
+			}
+		}
+	}()
+
+	// not yet due
+	testInstance.Advance(time.Second * 59)
+	// due, each move waits on its tick being received
+	testInstance.Advance(time.Second)
+	// a move spanning several intervals only ticks once
+	testInstance.Advance(time.Minute * 3)
+	testInstance.Set(start.Add(time.Minute * 5))
+	<-receiverDone
+	assert.Equal(t, []time.Time{start.Add(time.Minute), start.Add(time.Minute * 4), start.Add(time.Minute * 5)}, received)
+
+	// nothing is receiving ticks any more, stopping the ticker keeps moving the clock from waiting on them
+	stop()
+	stop()
+	testInstance.Advance(time.Hour)
+	assert.Equal(t, start.Add(time.Minute*65), testInstance.Now())
+}
@@ -0,0 +1,106 @@
+package srp
+
+import (
+	"context"
+	"errors"
+)
+
+type TieredCreatureRepoOptions struct {
+	// L1 configures the tier consulted first, which would typically be a small in-process cache with a short
+	// CacheDuration so that changes made by other processes are picked up before long
+	L1 CachingCreatureRepoOptions
+	// L2 configures the tier consulted when L1 misses, which would typically be a larger cache shared between processes,
+	// such as a RedisCreatureCache. Should L2 write behind, L1 leaves created creatures to be cached when first looked up,
+	// as they have no ID until L2 gets around to making them.
+	L2 CachingCreatureRepoOptions
+}
+
+// TieredCacheStats holds the stats of each tier of a TieredCreatureRepo. L1 misses are what make it to L2, and L2
+// misses are what make it to the raw repo.
+type TieredCacheStats struct {
+	L1 CacheStats
+	L2 CacheStats
+}
+
+// TieredCreatureRepo checks two tiers of cache before going to the raw repo. Nothing more than a pair of stacked
+// CachingCreatureRepos is involved, L1 using L2 as its raw repo, so creatures found in L2 are back-filled into L1 as a
+// matter of course, and writes make their way through both tiers on their way to the raw repo.
+type TieredCreatureRepo struct {
+	l1 *CachingCreatureRepo
+	l2 *CachingCreatureRepo
+}
+
+func NewTieredCreatureRepo(rawRepo RawCreatureRepo, options TieredCreatureRepoOptions) *TieredCreatureRepo {
+	l2 := NewCachingCreatureRepoWithOptions(rawRepo, options.L2)
+	return &TieredCreatureRepo{
+		l1: NewCachingCreatureRepoWithOptions(l2, options.L1),
+		l2: l2,
+	}
+}
+
+// L1 is the tier consulted first, for the likes of peeking into or exporting the stats of a single tier
+func (t *TieredCreatureRepo) L1() *CachingCreatureRepo {
+	return t.l1
+}
+
+// L2 is the tier consulted when L1 misses
+func (t *TieredCreatureRepo) L2() *CachingCreatureRepo {
+	return t.l2
+}
+
+// Close closes L1 and then L2, so that anything L1 has left to write makes it through L2
+func (t *TieredCreatureRepo) Close() error {
+	return errors.Join(t.l1.Close(), t.l2.Close())
+}
+
+func (t *TieredCreatureRepo) Stats() TieredCacheStats {
+	return TieredCacheStats{
+		L1: t.l1.Stats(),
+		L2: t.l2.Stats(),
+	}
+}
+
+func (t *TieredCreatureRepo) CreateCreature(ctx context.Context, name, description string) (Creature, error) {
+	return t.l1.CreateCreature(ctx, name, description)
+}
+
+func (t *TieredCreatureRepo) CreateCreatures(ctx context.Context, creatures []Creature) ([]Creature, error) {
+	return t.l1.CreateCreatures(ctx, creatures)
+}
+
+func (t *TieredCreatureRepo) GetCreature(ctx context.Context, id int64) (CreatureLookupResult, error) {
+	return t.l1.GetCreature(ctx, id)
+}
+
+func (t *TieredCreatureRepo) GetCreatures(ctx context.Context, ids []int64) (map[int64]CreatureLookupResult, error) {
+	return t.l1.GetCreatures(ctx, ids)
+}
+
+func (t *TieredCreatureRepo) GetCreatureByName(ctx context.Context, name string) (CreatureLookupResult, error) {
+	return t.l1.GetCreatureByName(ctx, name)
+}
+
+func (t *TieredCreatureRepo) UpdateCreature(ctx context.Context, id int64, update CreatureUpdate) (CreatureUpdateResult, error) {
+	return t.l1.UpdateCreature(ctx, id, update)
+}
+
+func (t *TieredCreatureRepo) DeleteCreature(ctx context.Context, id int64) (CreatureDeleteResult, error) {
+	return t.l1.DeleteCreature(ctx, id)
+}
+
+func (t *TieredCreatureRepo) ListCreatures(ctx context.Context, options ListOptions) (CreaturePage, error) {
+	return t.l1.ListCreatures(ctx, options)
+}
+
+// Invalidate drops the entry for an id from both tiers. L2 goes first, as otherwise L1 could be back-filled from L2
+// before L2 is done with.
+func (t *TieredCreatureRepo) Invalidate(ctx context.Context, id int64) {
+	t.l2.Invalidate(ctx, id)
+	t.l1.Invalidate(ctx, id)
+}
+
+// InvalidateAll drops every entry from both tiers, L2 first for the same reason as Invalidate
+func (t *TieredCreatureRepo) InvalidateAll(ctx context.Context) {
+	t.l2.InvalidateAll(ctx)
+	t.l1.InvalidateAll(ctx)
+}
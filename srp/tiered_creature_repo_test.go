@@ -0,0 +1,236 @@
+package srp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jonsabados/srp-sample/srp/srptest"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTieredCreatureRepo builds a tiered repo in front of rawRepo, with an in-memory L1 that caches for a minute and
+// an L2 in redis that caches for an hour
+func newTestTieredCreatureRepo(t *testing.T, rawRepo RawCreatureRepo, client redis.UniversalClient, clock Clock) *TieredCreatureRepo {
+	ret := NewTieredCreatureRepo(rawRepo, TieredCreatureRepoOptions{
+		L1: CachingCreatureRepoOptions{
+			CacheDuration: time.Minute,
+			Clock:         clock,
+		},
+		L2: CachingCreatureRepoOptions{
+			CacheDuration: time.Hour,
+			Cache:         NewRedisCreatureCache(client, ""),
+			Clock:         clock,
+		},
+	})
+	t.Cleanup(func() {
+		assert.NoError(t, ret.Close())
+	})
+	return ret
+}
+
+func TestTieredCreatureRepo_GetCreature(t *testing.T) {
+	ctx := context.Background()
+	clock := srptest.NewManualClock(time.Now())
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	bob := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(bob, nil).Once()
+	testInstance := newTestTieredCreatureRepo(t, rawRepo, client, clock)
+
+	lookup := func(expectedL1Hits, expectedL2Hits, expectedLoads uint64) {
+		t.Helper()
+		result, err := testInstance.GetCreature(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, bob, result)
+		stats := testInstance.Stats()
+		assert.Equal(t, expectedL1Hits, stats.L1.Hits, "L1 hits")
+		assert.Equal(t, expectedL2Hits, stats.L2.Hits, "L2 hits")
+		assert.Equal(t, expectedLoads, stats.L2.Loads, "loads from the raw repo")
+	}
+
+	// missing from both tiers, so loaded from the raw repo and cached in both
+	lookup(0, 0, 1)
+	lookup(1, 0, 1)
+
+	// L1 has forgotten about bob, but L2 still has him, and back-fills L1 with him
+	clock.Advance(time.Minute + time.Second)
+	lookup(1, 1, 1)
+	lookup(2, 1, 1)
+	stats := testInstance.Stats()
+	assert.Equal(t, uint64(2), stats.L1.Misses)
+	assert.Equal(t, uint64(1), stats.L2.Misses)
+
+	// a second process sharing L2 finds bob there from the get-go
+	other := newTestTieredCreatureRepo(t, NewMockRawCreatureRepo(t), client, clock)
+	result, err := other.GetCreature(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, bob, result)
+	assert.Equal(t, uint64(1), other.Stats().L2.Hits)
+}
+
+func TestTieredCreatureRepo_Writes(t *testing.T) {
+	ctx := context.Background()
+	clock := srptest.NewManualClock(time.Now())
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	newName := "robert"
+	robert := Creature{ID: 1, Name: "robert", Description: "likes testing"}
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().CreateCreature(mock.Anything, "bob", "likes testing").Return(Creature{ID: 1, Name: "bob", Description: "likes testing"}, nil).Once()
+	rawRepo.EXPECT().UpdateCreature(mock.Anything, int64(1), CreatureUpdate{Name: &newName}).Return(CreatureUpdateResult{ResultFound: true, Creature: robert}, nil).Once()
+	rawRepo.EXPECT().DeleteCreature(mock.Anything, int64(1)).Return(CreatureDeleteResult{ResultFound: true}, nil).Once()
+	testInstance := newTestTieredCreatureRepo(t, rawRepo, client, clock)
+
+	// writes pass through both tiers, each caching the outcome
+	_, err := testInstance.CreateCreature(ctx, "bob", "likes testing")
+	require.NoError(t, err)
+	_, err = testInstance.UpdateCreature(ctx, 1, CreatureUpdate{Name: &newName})
+	require.NoError(t, err)
+	for _, tier := range []*CachingCreatureRepo{testInstance.L1(), testInstance.L2()} {
+		entry, cached := tier.Peek(ctx, 1)
+		assert.True(t, cached)
+		assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: robert}, entry.Result)
+	}
+
+	_, err = testInstance.DeleteCreature(ctx, 1)
+	require.NoError(t, err)
+	for _, tier := range []*CachingCreatureRepo{testInstance.L1(), testInstance.L2()} {
+		entry, cached := tier.Peek(ctx, 1)
+		assert.True(t, cached)
+		assert.Equal(t, CreatureLookupResult{ResultFound: false}, entry.Result)
+	}
+}
+
+func TestTieredCreatureRepo_Invalidate(t *testing.T) {
+	ctx := context.Background()
+	clock := srptest.NewManualClock(time.Now())
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	bob := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 1, Name: "bob", Description: "likes testing"},
+	}
+	alice := CreatureLookupResult{
+		ResultFound: true,
+		Creature:    Creature{ID: 2, Name: "alice", Description: "likes reviewing"},
+	}
+
+	testCases := []struct {
+		name         string
+		invalidate   func(testInstance *TieredCreatureRepo)
+		expectedGone []int64
+	}{
+		{
+			name: "Invalidate",
+			invalidate: func(testInstance *TieredCreatureRepo) {
+				testInstance.Invalidate(ctx, 1)
+			},
+			expectedGone: []int64{1},
+		},
+		{
+			name: "InvalidateAll",
+			invalidate: func(testInstance *TieredCreatureRepo) {
+				testInstance.InvalidateAll(ctx)
+			},
+			expectedGone: []int64{1, 2},
+		},
+		{
+			name: "change notification",
+			invalidate: func(testInstance *TieredCreatureRepo) {
+				listener := &InvalidationListener{logger: testInstance.l1.logger}
+				listener.Subscribe(testInstance)
+				listener.handle(ctx, &pq.Notification{Channel: DefaultInvalidationChannel, Extra: "1"})
+			},
+			expectedGone: []int64{1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server.FlushAll()
+			rawRepo := NewMockRawCreatureRepo(t)
+			rawRepo.EXPECT().GetCreature(mock.Anything, int64(1)).Return(bob, nil).Once()
+			rawRepo.EXPECT().GetCreature(mock.Anything, int64(2)).Return(alice, nil).Once()
+			testInstance := newTestTieredCreatureRepo(t, rawRepo, client, clock)
+			for _, id := range []int64{1, 2} {
+				_, err := testInstance.GetCreature(ctx, id)
+				require.NoError(t, err)
+			}
+
+			tc.invalidate(testInstance)
+
+			gone := make(map[int64]bool)
+			for _, id := range tc.expectedGone {
+				gone[id] = true
+			}
+			for _, id := range []int64{1, 2} {
+				for _, tier := range []*CachingCreatureRepo{testInstance.L1(), testInstance.L2()} {
+					_, cached := tier.Peek(ctx, id)
+					assert.Equal(t, !gone[id], cached, "id %d", id)
+				}
+			}
+
+			// with both tiers cleared the next lookup goes all the way to the raw repo
+			for _, id := range tc.expectedGone {
+				rawRepo.EXPECT().GetCreature(mock.Anything, id).Return(bob, nil).Once()
+				_, err := testInstance.GetCreature(ctx, id)
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTieredCreatureRepo_L2WriteBehind(t *testing.T) {
+	ctx := context.Background()
+	clock := srptest.NewManualClock(time.Now())
+
+	bob := Creature{ID: 1, Name: "bob", Description: "likes testing"}
+	rawRepo := NewMockRawCreatureRepo(t)
+	rawRepo.EXPECT().CreateCreatures(mock.Anything, []Creature{{Name: "bob", Description: "likes testing"}}).Return([]Creature{bob}, nil).Once()
+	testInstance := NewTieredCreatureRepo(rawRepo, TieredCreatureRepoOptions{
+		L1: CachingCreatureRepoOptions{
+			CacheDuration: time.Minute,
+			Clock:         clock,
+		},
+		L2: CachingCreatureRepoOptions{
+			CacheDuration:            time.Hour,
+			Clock:                    clock,
+			WritePolicy:              WriteBehind,
+			WriteBehindFlushInterval: time.Hour,
+		},
+	})
+	defer testInstance.Close()
+
+	created, err := testInstance.CreateCreature(ctx, "bob", "likes testing")
+	require.NoError(t, err)
+	assert.Equal(t, Creature{Name: "bob", Description: "likes testing"}, created)
+	// L1 has nothing to key the creature by until L2 has made it
+	_, cached := testInstance.L1().Peek(ctx, 0)
+	assert.False(t, cached)
+	assert.Equal(t, 0, testInstance.Stats().L1.Size)
+
+	require.NoError(t, testInstance.L2().Flush(ctx))
+	// no expectations for lookups on the raw repo, so bob comes from L2, under the id it was given
+	result, err := testInstance.GetCreatureByName(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: bob}, result)
+	result, err = testInstance.GetCreatureByName(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, CreatureLookupResult{ResultFound: true, Creature: bob}, result)
+	assert.Equal(t, uint64(1), testInstance.Stats().L1.Hits)
+}
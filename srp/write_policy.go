@@ -0,0 +1,167 @@
+package srp
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WritePolicy decides how CachingCreatureRepo handles writes
+type WritePolicy int
+
+const (
+	// WriteThrough makes writes against the raw repo and caches the outcome
+	WriteThrough WritePolicy = iota
+	// WriteAround makes writes against the raw repo and drops any entries they affect, leaving creatures to be cached
+	// when next looked up. Useful when what gets written is rarely read back soon after.
+	WriteAround
+	// WriteBehind acknowledges creates as soon as they are queued, making them against the raw repo, and caching the
+	// outcome, in the background. Updates and deletes are written through, as their outcome isn't known until made.
+	//
+	// As ids are handed out by the database, creatures returned by CreateCreature have an ID of zero, and creatures
+	// can't be looked up until their create has been made. Creates that fail once made, such as those for names already
+	// in use, can only be logged and counted in CacheStats.WriteErrors. Queued creates are made in batches, each a single
+	// call to the raw repo's CreateCreatures.
+	WriteBehind
+)
+
+const (
+	DefaultWriteBehindQueueSize     = 1000
+	DefaultWriteBehindBatchSize     = 100
+	DefaultWriteBehindFlushInterval = time.Second
+)
+
+// pendingCreate is a create acknowledged under WriteBehind that has yet to be made
+type pendingCreate struct {
+	// ctx is the context of the CreateCreature call, without its cancellation, so that any values it carries make it to
+	// the raw repo
+	ctx         context.Context
+	name        string
+	description string
+}
+
+// writeBehindQueue holds creates that have been acknowledged but not yet made, handing them over in batches, in the order
+// they were queued, to be written. A batch is handed over once it is full, when the flush interval passes, when asked
+// to flush, and when closing.
+type writeBehindQueue struct {
+	pending       chan pendingCreate
+	flushRequests chan chan struct{}
+	batchSize     int
+	write         func(batch []pendingCreate)
+	// waiting counts creates queued but not yet written, including those taken off pending to make up a batch
+	waiting atomic.Int64
+
+	// closed is set once the queue no longer accepts creates. mutex guards closed, and is held for reading while queueing
+	// so that closing waits on anything part way through being queued.
+	closed bool
+	mutex  sync.RWMutex
+	done   chan struct{}
+}
+
+// newWriteBehindQueue creates a queue handing over batches at least every flushInterval according to clock
+func newWriteBehindQueue(queueSize, batchSize int, flushInterval time.Duration, clock Clock, write func(batch []pendingCreate)) *writeBehindQueue {
+	ret := &writeBehindQueue{
+		pending:       make(chan pendingCreate, queueSize),
+		flushRequests: make(chan chan struct{}),
+		batchSize:     batchSize,
+		write:         write,
+		done:          make(chan struct{}),
+	}
+	// the ticker is made up front, so that anything driving it through the clock can count on it from here on
+	ticks, stop := newTicker(clock, flushInterval)
+	go ret.run(ticks, stop)
+	return ret
+}
+
+// enqueue queues a create, waiting for room if the queue is full. False is returned if the queue has been closed, in
+// which case it is up to the caller to make the create themselves.
+func (q *writeBehindQueue) enqueue(ctx context.Context, create pendingCreate) (bool, error) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+	if q.closed {
+		return false, nil
+	}
+	q.waiting.Add(1)
+	select {
+	case q.pending <- create:
+		return true, nil
+	case <-ctx.Done():
+		q.waiting.Add(-1)
+		return false, ctx.Err()
+	}
+}
+
+// flush waits for everything queued so far to be written
+func (q *writeBehindQueue) flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case q.flushRequests <- flushed:
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close stops accepting creates, and waits for everything already queued to be written
+func (q *writeBehindQueue) close() {
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+		<-q.done
+		return
+	}
+	q.closed = true
+	q.mutex.Unlock()
+	close(q.pending)
+	<-q.done
+}
+
+func (q *writeBehindQueue) len() int {
+	return int(q.waiting.Load())
+}
+
+func (q *writeBehindQueue) run(ticks <-chan time.Time, stop func()) {
+	defer close(q.done)
+	defer stop()
+	batch := make([]pendingCreate, 0, q.batchSize)
+	writeBatch := func() {
+		if len(batch) > 0 {
+			q.write(batch)
+			q.waiting.Add(-int64(len(batch)))
+			batch = batch[:0]
+		}
+	}
+	for {
+		select {
+		case create, open := <-q.pending:
+			if !open {
+				writeBatch()
+				return
+			}
+			batch = append(batch, create)
+			if len(batch) >= q.batchSize {
+				writeBatch()
+			}
+		case <-ticks:
+			writeBatch()
+		case flushed := <-q.flushRequests:
+			// anything queued before the flush was asked for is already waiting in pending
+			for queued := len(q.pending); queued > 0; queued-- {
+				batch = append(batch, <-q.pending)
+				if len(batch) >= q.batchSize {
+					writeBatch()
+				}
+			}
+			writeBatch()
+			close(flushed)
+		}
+	}
+}